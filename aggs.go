@@ -0,0 +1,275 @@
+package esquerydsl
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Agg is used to construct a single ES aggregation. The Type attr is the
+// aggregation kind (eg: "terms"), the Body attr is what gets emitted under
+// that kind and the Aggs attr holds any sub aggregations, keyed by name
+type Agg struct {
+	Type string
+	Body interface{}
+	Aggs map[string]Agg
+}
+
+// TermsAgg builds a terms aggregation over field. The size is only
+// emitted when it is greater than zero
+func TermsAgg(field string, size int) Agg {
+	body := map[string]interface{}{
+		"field": field,
+	}
+	if size > 0 {
+		body["size"] = size
+	}
+
+	return Agg{Type: "terms", Body: body}
+}
+
+// AvgAgg builds an avg metric aggregation over field
+func AvgAgg(field string) Agg {
+	return Agg{
+		Type: "avg",
+		Body: map[string]interface{}{"field": field},
+	}
+}
+
+// SumAgg builds a sum metric aggregation over field
+func SumAgg(field string) Agg {
+	return Agg{
+		Type: "sum",
+		Body: map[string]interface{}{"field": field},
+	}
+}
+
+// ES only accepts single calendar units as a calendar_interval, anything
+// else (eg: "12h" or "90m") needs to be sent as a fixed_interval instead
+var calendarIntervals = map[string]bool{
+	"minute": true, "1m": true,
+	"hour": true, "1h": true,
+	"day": true, "1d": true,
+	"week": true, "1w": true,
+	"month": true, "1M": true,
+	"quarter": true, "1q": true,
+	"year": true, "1y": true,
+}
+
+// DateHistogramAgg builds a date_histogram aggregation over field. The
+// interval is sent as a calendar_interval when it is a single calendar unit
+// (eg: "1d" or "month") and as a fixed_interval otherwise. Options such as
+// time_zone and min_doc_count can be set via With
+func DateHistogramAgg(field, interval string) Agg {
+	intervalKey := "fixed_interval"
+	if calendarIntervals[interval] {
+		intervalKey = "calendar_interval"
+	}
+
+	return Agg{
+		Type: "date_histogram",
+		Body: map[string]interface{}{
+			"field":     field,
+			intervalKey: interval,
+		},
+	}
+}
+
+// CardinalityAgg builds a cardinality aggregation, counting the
+// approximate number of distinct values of field
+func CardinalityAgg(field string) Agg {
+	return Agg{
+		Type: "cardinality",
+		Body: map[string]interface{}{"field": field},
+	}
+}
+
+// PercentilesAgg builds a percentiles aggregation over field. The percents
+// are only emitted when provided, otherwise ES falls back to its defaults
+func PercentilesAgg(field string, percents ...float64) Agg {
+	body := map[string]interface{}{
+		"field": field,
+	}
+	if len(percents) > 0 {
+		body["percents"] = percents
+	}
+
+	return Agg{Type: "percentiles", Body: body}
+}
+
+// HistogramAgg builds a histogram aggregation over field, bucketing
+// numeric values into interval sized buckets
+func HistogramAgg(field string, interval float64) Agg {
+	return Agg{
+		Type: "histogram",
+		Body: map[string]interface{}{
+			"field":    field,
+			"interval": interval,
+		},
+	}
+}
+
+// CompositeSource is a single named source of a composite aggregation.
+// The Source attr should be a terms, histogram or date_histogram Agg
+type CompositeSource struct {
+	Name   string
+	Source Agg
+}
+
+// MarshalJSON will convert the CompositeSource struct into its ES representation
+func (c CompositeSource) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]Agg{
+		c.Name: c.Source,
+	})
+}
+
+// CompositeAgg builds a composite aggregation used to paginate through
+// every bucket of its sources. The after key is the "after_key" returned by
+// the previous page and is omitted when nil, as is a size of zero
+func CompositeAgg(sources []CompositeSource, size int, after map[string]interface{}) Agg {
+	body := map[string]interface{}{
+		"sources": sources,
+	}
+	if size > 0 {
+		body["size"] = size
+	}
+	if after != nil {
+		body["after"] = after
+	}
+
+	return Agg{Type: "composite", Body: body}
+}
+
+// TopHitsAgg builds a top_hits aggregation returning a sample of the
+// documents in each bucket. Sort and source are omitted when empty, in
+// which case ES sorts by score and returns the whole _source
+func TopHitsAgg(size int, sort []map[string]string, source []string) Agg {
+	body := map[string]interface{}{
+		"size": size,
+	}
+	if len(sort) > 0 {
+		body["sort"] = sort
+	}
+	if len(source) > 0 {
+		body["_source"] = source
+	}
+
+	return Agg{Type: "top_hits", Body: body}
+}
+
+// FilterAgg builds a filter aggregation, narrowing its sub aggregations
+// down to the documents matched by q
+func FilterAgg(q QueryItem) Agg {
+	return Agg{Type: "filter", Body: toLeafQuery(q)}
+}
+
+// FiltersAgg builds a filters aggregation with one bucket per named query
+func FiltersAgg(filters map[string]QueryItem) Agg {
+	queries := make(map[string]leafQuery, len(filters))
+	for name, q := range filters {
+		queries[name] = toLeafQuery(q)
+	}
+
+	return Agg{
+		Type: "filters",
+		Body: map[string]interface{}{"filters": queries},
+	}
+}
+
+// NestedAgg builds a nested aggregation so that its sub aggregations
+// run against the nested documents found under path
+func NestedAgg(path string) Agg {
+	return Agg{
+		Type: "nested",
+		Body: map[string]interface{}{"path": path},
+	}
+}
+
+// DerivativeAgg builds a derivative pipeline aggregation over the metric
+// found at bucketsPath. It must be nested under a histogram or date_histogram
+func DerivativeAgg(bucketsPath string) Agg {
+	return Agg{
+		Type: "derivative",
+		Body: map[string]interface{}{"buckets_path": bucketsPath},
+	}
+}
+
+// CumulativeSumAgg builds a cumulative_sum pipeline aggregation over the metric
+// found at bucketsPath. It must be nested under a histogram or date_histogram
+func CumulativeSumAgg(bucketsPath string) Agg {
+	return Agg{
+		Type: "cumulative_sum",
+		Body: map[string]interface{}{"buckets_path": bucketsPath},
+	}
+}
+
+// BucketScriptAgg builds a bucket_script pipeline aggregation. The
+// bucketsPath map binds script variable names to sibling metric paths
+func BucketScriptAgg(bucketsPath map[string]string, script string) Agg {
+	return Agg{
+		Type: "bucket_script",
+		Body: map[string]interface{}{
+			"buckets_path": bucketsPath,
+			"script":       script,
+		},
+	}
+}
+
+// With returns a copy of the aggregation with the key option set to value.
+// It only applies to aggregations whose Body is a map of options
+func (a Agg) With(key string, value interface{}) Agg {
+	params, ok := a.Body.(map[string]interface{})
+	if !ok {
+		return a
+	}
+
+	body := make(map[string]interface{}, len(params)+1)
+	for k, v := range params {
+		body[k] = v
+	}
+	body[key] = value
+	a.Body = body
+
+	return a
+}
+
+// SubAgg returns a copy of the aggregation with sub nested under it as name
+func (a Agg) SubAgg(name string, sub Agg) Agg {
+	aggs := make(map[string]Agg, len(a.Aggs)+1)
+	for key, agg := range a.Aggs {
+		aggs[key] = agg
+	}
+	aggs[name] = sub
+	a.Aggs = aggs
+
+	return a
+}
+
+// MarshalJSON will convert the Agg struct into its ES representation.
+// The aggregation kind is always emitted ahead of any sub aggregations
+func (a Agg) MarshalJSON() ([]byte, error) {
+	aggType, err := json.Marshal(a.Type)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(a.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	buf.Write(aggType)
+	buf.WriteString(":")
+	buf.Write(body)
+	if len(a.Aggs) > 0 {
+		aggs, err := json.Marshal(a.Aggs)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`,"aggs":`)
+		buf.Write(aggs)
+	}
+	buf.WriteString("}")
+
+	return buf.Bytes(), nil
+}
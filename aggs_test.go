@@ -0,0 +1,180 @@
+package esquerydsl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTermsAggWithNestedAvg(t *testing.T) {
+	body, _ := json.Marshal(map[string]Agg{
+		"by_status": TermsAgg("status", 10).SubAgg("avg_price", AvgAgg("price")),
+	})
+
+	expected := `{"by_status":{"terms":{"field":"status","size":10},"aggs":{"avg_price":{"avg":{"field":"price"}}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestQueryDocAggs(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "status",
+				Value: "published",
+				Type:  Term,
+			},
+		},
+		Aggs: map[string]Agg{
+			"by_status": TermsAgg("status", 10).SubAgg("avg_price", AvgAgg("price")),
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"term":{"status":"published"}}]}},"aggs":{"by_status":{"terms":{"field":"status","size":10},"aggs":{"avg_price":{"avg":{"field":"price"}}}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestDateHistogramAgg(t *testing.T) {
+	body, _ := json.Marshal(map[string]Agg{
+		"per_day": DateHistogramAgg("created_at", "1d").
+			With("time_zone", "America/New_York").
+			With("min_doc_count", 0).
+			SubAgg("revenue", SumAgg("price")),
+	})
+
+	expected := `{"per_day":{"date_histogram":{"calendar_interval":"1d","field":"created_at","min_doc_count":0,"time_zone":"America/New_York"},"aggs":{"revenue":{"sum":{"field":"price"}}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestDateHistogramAggFixedInterval(t *testing.T) {
+	body, _ := json.Marshal(DateHistogramAgg("created_at", "12h"))
+
+	expected := `{"date_histogram":{"field":"created_at","fixed_interval":"12h"}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestCompositeAgg(t *testing.T) {
+	body, _ := json.Marshal(CompositeAgg(
+		[]CompositeSource{
+			{Name: "status", Source: TermsAgg("status", 0)},
+			{Name: "day", Source: DateHistogramAgg("created_at", "1d")},
+		},
+		100,
+		map[string]interface{}{"status": "published", "day": 1609459200000},
+	))
+
+	expected := `{"composite":{"after":{"day":1609459200000,"status":"published"},"size":100,"sources":[{"status":{"terms":{"field":"status"}}},{"day":{"date_histogram":{"calendar_interval":"1d","field":"created_at"}}}]}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestTopHitsAgg(t *testing.T) {
+	body, _ := json.Marshal(map[string]Agg{
+		"by_author": TermsAgg("author", 5).SubAgg("latest", TopHitsAgg(
+			1,
+			[]map[string]string{{"date": "desc"}},
+			[]string{"title", "date"},
+		)),
+	})
+
+	expected := `{"by_author":{"terms":{"field":"author","size":5},"aggs":{"latest":{"top_hits":{"_source":["title","date"],"size":1,"sort":[{"date":"desc"}]}}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestFilterAgg(t *testing.T) {
+	body, _ := json.Marshal(map[string]Agg{
+		"published": FilterAgg(QueryItem{
+			Field: "status",
+			Value: "published",
+			Type:  Term,
+		}).SubAgg("avg_price", AvgAgg("price")),
+	})
+
+	expected := `{"published":{"filter":{"term":{"status":"published"}},"aggs":{"avg_price":{"avg":{"field":"price"}}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestFiltersAgg(t *testing.T) {
+	body, _ := json.Marshal(FiltersAgg(map[string]QueryItem{
+		"errors":   {Field: "level", Value: "error", Type: Term},
+		"warnings": {Field: "level", Value: "warning", Type: Term},
+	}))
+
+	expected := `{"filters":{"filters":{"errors":{"term":{"level":"error"}},"warnings":{"term":{"level":"warning"}}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestCardinalityAgg(t *testing.T) {
+	body, _ := json.Marshal(CardinalityAgg("user_id"))
+
+	expected := `{"cardinality":{"field":"user_id"}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestPercentilesAgg(t *testing.T) {
+	body, _ := json.Marshal(PercentilesAgg("latency", 50, 95, 99.9))
+
+	expected := `{"percentiles":{"field":"latency","percents":[50,95,99.9]}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	body, _ = json.Marshal(PercentilesAgg("latency"))
+
+	expected = `{"percentiles":{"field":"latency"}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestNestedAgg(t *testing.T) {
+	body, _ := json.Marshal(map[string]Agg{
+		"items": NestedAgg("items").SubAgg("by_sku", TermsAgg("items.sku", 10)),
+	})
+
+	expected := `{"items":{"nested":{"path":"items"},"aggs":{"by_sku":{"terms":{"field":"items.sku","size":10}}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestDerivativeAgg(t *testing.T) {
+	body, _ := json.Marshal(map[string]Agg{
+		"per_day": DateHistogramAgg("created_at", "1d").
+			SubAgg("revenue", SumAgg("price")).
+			SubAgg("revenue_change", DerivativeAgg("revenue")),
+	})
+
+	expected := `{"per_day":{"date_histogram":{"calendar_interval":"1d","field":"created_at"},"aggs":{"revenue":{"sum":{"field":"price"}},"revenue_change":{"derivative":{"buckets_path":"revenue"}}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestBucketScriptAgg(t *testing.T) {
+	body, _ := json.Marshal(BucketScriptAgg(
+		map[string]string{"sales": "revenue", "count": "_count"},
+		"params.sales / params.count",
+	))
+
+	expected := `{"bucket_script":{"buckets_path":{"count":"_count","sales":"revenue"},"script":"params.sales / params.count"}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
@@ -0,0 +1,12 @@
+package esquerydsl
+
+// AnalyzeDoc is used to construct the JSON body of an _analyze API request,
+// which is handy for debugging how text gets tokenized. Set either Analyzer,
+// Field (to use the analyzer mapped to it) or Tokenizer and Filter
+type AnalyzeDoc struct {
+	Analyzer  string   `json:"analyzer,omitempty"`
+	Field     string   `json:"field,omitempty"`
+	Tokenizer string   `json:"tokenizer,omitempty"`
+	Filter    []string `json:"filter,omitempty"`
+	Text      string   `json:"text"`
+}
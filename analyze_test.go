@@ -0,0 +1,43 @@
+package esquerydsl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAnalyzeDocAnalyzer(t *testing.T) {
+	body, _ := json.Marshal(AnalyzeDoc{
+		Analyzer: "standard",
+		Text:     "The quick fox",
+	})
+
+	expected := `{"analyzer":"standard","text":"The quick fox"}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestAnalyzeDocField(t *testing.T) {
+	body, _ := json.Marshal(AnalyzeDoc{
+		Field: "title",
+		Text:  "kimchy!",
+	})
+
+	expected := `{"field":"title","text":"kimchy!"}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestAnalyzeDocTokenizer(t *testing.T) {
+	body, _ := json.Marshal(AnalyzeDoc{
+		Tokenizer: "whitespace",
+		Filter:    []string{"lowercase", "asciifolding"},
+		Text:      "Déjà Vu",
+	})
+
+	expected := `{"tokenizer":"whitespace","filter":["lowercase","asciifolding"],"text":"Déjà Vu"}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
@@ -0,0 +1,50 @@
+package esquerydsl
+
+// Collapse is used to collapse search hits on the values of Field, so that
+// only the top hit per value is returned. InnerHits, when set, expands each
+// collapsed hit with other hits sharing the same value.
+// MaxConcurrentGroupSearches caps how many inner_hits searches ES runs
+// concurrently per request
+type Collapse struct {
+	Field                      string     `json:"field"`
+	InnerHits                  *InnerHits `json:"inner_hits,omitempty"`
+	MaxConcurrentGroupSearches int        `json:"max_concurrent_group_searches,omitempty"`
+}
+
+// InnerHits describes the extra hits returned alongside a collapsed hit.
+// ES requires a Name and, as it only returns a handful of inner hits by
+// default, Size should always be set too; Validate reports both. Sort takes
+// the same clauses as QueryDoc.SortBy. Collapse, when set, collapses the
+// inner hits themselves on a second field (eg: group by thread, then show
+// the latest hit per author). Source filters the inner hits' _source
+// independently of the request's own Source. Version and SeqNoPrimaryTerm
+// return the version info of each inner hit
+type InnerHits struct {
+	Name     string        `json:"name,omitempty"`
+	Size     int           `json:"size,omitempty"`
+	From     int           `json:"from,omitempty"`
+	Sort     []interface{} `json:"sort,omitempty"`
+	Collapse *Collapse     `json:"collapse,omitempty"`
+	Source   interface{}   `json:"_source,omitempty"`
+
+	Version          bool `json:"version,omitempty"`
+	SeqNoPrimaryTerm bool `json:"seq_no_primary_term,omitempty"`
+}
+
+// GroupCountAgg is the name of the aggregation CollapseWithCount adds
+const GroupCountAgg = "group_count"
+
+// CollapseWithCount returns a copy of the query collapsed on field, along
+// with a cardinality aggregation named GroupCountAgg on the same field that
+// gives the (approximate) total number of groups
+func (query QueryDoc) CollapseWithCount(field string) QueryDoc {
+	aggs := make(map[string]Agg, len(query.Aggs)+1)
+	for name, agg := range query.Aggs {
+		aggs[name] = agg
+	}
+	aggs[GroupCountAgg] = CardinalityAgg(field)
+
+	query.Aggs = aggs
+	query.Collapse = &Collapse{Field: field}
+	return query
+}
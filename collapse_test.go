@@ -0,0 +1,108 @@
+package esquerydsl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCollapseSecondLevel(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Sort:  []map[string]string{{"date": "desc"}},
+		And: []QueryItem{
+			{
+				Field: "body",
+				Value: "elasticsearch",
+				Type:  Match,
+			},
+		},
+		Collapse: &Collapse{
+			Field: "thread_id",
+			InnerHits: &InnerHits{
+				Name:     "by_author",
+				Size:     5,
+				Collapse: &Collapse{Field: "author"},
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"match":{"body":"elasticsearch"}}]}},"sort":[{"date":"desc"}],"collapse":{"field":"thread_id","inner_hits":{"name":"by_author","size":5,"collapse":{"field":"author"}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestCollapseInnerHitsSort(t *testing.T) {
+	body, _ := json.Marshal(Collapse{
+		Field: "user",
+		InnerHits: &InnerHits{
+			Name: "latest",
+			Size: 3,
+			Sort: []interface{}{map[string]string{"date": "desc"}},
+		},
+	})
+
+	expected := `{"field":"user","inner_hits":{"name":"latest","size":3,"sort":[{"date":"desc"}]}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestCollapseMaxConcurrentGroupSearches(t *testing.T) {
+	body, _ := json.Marshal(Collapse{
+		Field: "u",
+		InnerHits: &InnerHits{
+			Name: "latest",
+			Size: 3,
+		},
+		MaxConcurrentGroupSearches: 4,
+	})
+
+	expected := `{"field":"u","inner_hits":{"name":"latest","size":3},"max_concurrent_group_searches":4}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestCollapseWithCount(t *testing.T) {
+	query := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "body",
+				Value: "elasticsearch",
+				Type:  Match,
+			},
+		},
+		Aggs: map[string]Agg{
+			"by_status": TermsAgg("status", 0),
+		},
+	}
+
+	body, _ := json.Marshal(query.CollapseWithCount("user"))
+	expected := `{"query":{"bool":{"must":[{"match":{"body":"elasticsearch"}}]}},"aggs":{"by_status":{"terms":{"field":"status"}},"group_count":{"cardinality":{"field":"user"}}},"collapse":{"field":"user"}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	if _, ok := query.Aggs[GroupCountAgg]; ok {
+		t.Errorf("\nUnexpected %q agg on the original query", GroupCountAgg)
+	}
+}
+
+func TestCollapseInnerHitsVersion(t *testing.T) {
+	body, _ := json.Marshal(Collapse{
+		Field: "user",
+		InnerHits: &InnerHits{
+			Name:             "latest",
+			Size:             1,
+			Version:          true,
+			SeqNoPrimaryTerm: true,
+		},
+	})
+
+	expected := `{"field":"user","inner_hits":{"name":"latest","size":1,"version":true,"seq_no_primary_term":true}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
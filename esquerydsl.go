@@ -3,9 +3,15 @@
 package esquerydsl
 
 import (
+	"bytes"
+	"encoding"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -26,6 +32,15 @@ const (
 	Nested
 	NestedQuery
 	HasChild
+	IDs
+	MultiMatch
+	HasParent
+	MatchAll
+	MatchNone
+	MatchPhrase
+	Prefix
+	FunctionScore
+	Regexp
 )
 
 // QueryTypeErr is a custom err returned if we are trying to stringify
@@ -38,24 +53,99 @@ func (e *QueryTypeErr) Error() string {
 	return fmt.Sprintf("Type %d is not supported", e.typeVal)
 }
 
+// MissingFieldErr is a custom err returned if we are trying to marshal
+// a QueryItem whose type requires a Field without one
+type MissingFieldErr struct {
+	typeVal QueryType
+}
+
+func (e *MissingFieldErr) Error() string {
+	queryType, err := e.typeVal.String()
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("Type %s requires a Field", queryType)
+}
+
+// ValueTypeErr is a custom err returned if we are trying to marshal
+// a QueryItem whose Value is of a Go type its query type can't handle
+type ValueTypeErr struct {
+	Type QueryType
+	Got  string
+}
+
+func (e *ValueTypeErr) Error() string {
+	queryType, err := e.Type.String()
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("Type %s does not support values of type %s", queryType, e.Got)
+}
+
+// ClauseErr is a custom err returned in StrictMode if we are trying to
+// marshal a query wrapped under an unknown clause name
+type ClauseErr struct {
+	Clause string
+}
+
+func (e *ClauseErr) Error() string {
+	return fmt.Sprintf("Clause %q is not one of and, or, not or filter", e.Clause)
+}
+
+// StrictMode turns silent defaults into marshaling errors: a ClauseErr for
+// queries wrapped under an unknown clause name, which otherwise default to
+// and, and a ValueTypeErr for non scalar values in queries such as match and
+// term, which otherwise marshal into JSON that ES rejects. Set it during setup
+var StrictMode = false
+
+// These leaf query types are keyed by the document attr they query
+// against and so can't be marshaled without a Field
+var fieldRequired = map[QueryType]bool{
+	Match:       true,
+	MatchPhrase: true,
+	Term:        true,
+	Terms:       true,
+	Wildcard:    true,
+	Prefix:      true,
+	Regexp:      true,
+	Range:       true,
+	Exists:      true,
+}
+
+// queryTypeTokens holds the ES token of each QueryType, in iota order
+var queryTypeTokens = [...]string{
+	"match",
+	"term",
+	"terms",
+	"wildcard",
+	"range",
+	"exists",
+	"query_string",
+	"nested",
+	"nested_query",
+	"has_child",
+	"ids",
+	"multi_match",
+	"has_parent",
+	"match_all",
+	"match_none",
+	"match_phrase",
+	"prefix",
+	"function_score",
+	"regexp",
+}
+
+// IsValid reports whether the QueryType maps onto a supported ES token
+func (qt QueryType) IsValid() bool {
+	return qt >= 0 && int(qt) < len(queryTypeTokens)
+}
+
 func (qt QueryType) String() (string, error) {
-	convs := [...]string{
-		"match",
-		"term",
-		"terms",
-		"wildcard",
-		"range",
-		"exists",
-		"query_string",
-		"nested",
-		"nested_query",
-		"has_child",
-	}
-	if int(qt) > len(convs) {
+	if !qt.IsValid() {
 		return "", &QueryTypeErr{typeVal: qt}
 	}
 
-	return convs[qt], nil
+	return queryTypeTokens[qt], nil
 }
 
 // QueryDoc is the main public struct that ought to be used to
@@ -72,6 +162,266 @@ type QueryDoc struct {
 	Or          []QueryItem
 	Filter      []QueryItem
 	PageSize    int
+
+	// Boost, MinimumShouldMatch and Name, when set, are emitted on the
+	// bool query that wraps the clause lists, Name being its _name
+	Boost              *float64
+	MinimumShouldMatch interface{}
+	Name               string
+
+	// Aggs holds the request's aggregations, keyed by name
+	Aggs map[string]Agg
+
+	// Collapse, when set, collapses hits on the values of a field
+	Collapse *Collapse
+
+	// SortBy supersedes Sort when set. Unlike Sort, it can mix simple
+	// {"field": "order"} maps and SortField clauses in a single list
+	SortBy []interface{}
+
+	// Source controls which parts of each hit's _source are returned.
+	// It accepts anything ES does, including a bool, a single pattern
+	// string (eg: "obj.*") and a SourceFilter
+	Source interface{}
+
+	// Version and SeqNoPrimaryTerm ask ES to return each hit's version
+	// and sequence number/primary term, for optimistic concurrency control.
+	// TerminateAfter caps the number of documents collected per shard
+	Version          bool
+	SeqNoPrimaryTerm bool
+	TerminateAfter   int
+
+	// Fields lists the fields to retrieve through the fields API. Entries
+	// can be field names (or patterns) as well as FieldAndFormat values
+	Fields []interface{}
+
+	// Profile asks ES for a detailed timing breakdown of the query
+	Profile bool
+
+	// TrackScores asks ES to still compute each hit's _score when
+	// sorting on a field, which it otherwise skips
+	TrackScores bool
+
+	// Scroll is the keep alive (eg: "1m") of a scroll search. It is a URL
+	// param rather than part of the body, so it is never marshaled; read it
+	// back when sending the request, then page with ScrollContinue
+	Scroll string
+
+	// IgnoreUnavailable and AllowNoIndices only affect the header line
+	// written by MultiSearchDoc, letting a search span index patterns that
+	// may not all exist. AllowNoIndices is omitted when nil. ExpandWildcards
+	// ("open", "closed", "hidden", "all" or a comma separated mix) picks
+	// which index states the patterns match
+	IgnoreUnavailable bool
+	AllowNoIndices    *bool
+	ExpandWildcards   string
+
+	// SearchType (eg: "dfs_query_then_fetch") is a request param, so it
+	// too only goes in the header line written by MultiSearchDoc
+	SearchType string
+
+	// Indices lists the indices to search. When set, it is preferred over
+	// Index and written comma separated in the MultiSearchDoc header line
+	Indices []string
+
+	// Routing lists the routing values restricting the search to the
+	// shards they map to, written comma separated in the header line
+	Routing []string
+
+	// sizeSet and fromSet track whether WithSize and WithFrom were
+	// used, so that an explicit size or from of 0 is still emitted
+	sizeSet bool
+	fromSet bool
+
+	// unknownClause holds the clause name a wrapped query was built with
+	// when it wasn't a known one, for StrictMode to report
+	unknownClause string
+}
+
+// FieldAndFormat is the object form of a fields API entry, used to
+// request a field's values in a specific format (eg: "epoch_millis")
+type FieldAndFormat struct {
+	Field  string `json:"field"`
+	Format string `json:"format,omitempty"`
+}
+
+// SourceFilter is the includes/excludes form of _source filtering.
+// Both lists accept wildcard patterns (eg: "obj.*")
+type SourceFilter struct {
+	Includes []string `json:"includes,omitempty"`
+	Excludes []string `json:"excludes,omitempty"`
+}
+
+// Bool builds a QueryDoc out of all four bool clause lists in one call.
+// Any of the lists may be nil
+func Bool(must, should, mustNot, filter []QueryItem) QueryDoc {
+	return QueryDoc{
+		And:    must,
+		Or:     should,
+		Not:    mustNot,
+		Filter: filter,
+	}
+}
+
+// Scored builds a QueryDoc where filter narrows down the matching documents
+// without affecting their score, which ES can cache, and must both narrows
+// them down further and computes the _score hits are ranked by
+func Scored(must []QueryItem, filter []QueryItem) QueryDoc {
+	return QueryDoc{
+		And:    must,
+		Filter: filter,
+	}
+}
+
+// WithSize returns a copy of the query with Size set to n. Unlike setting
+// Size directly, a size of 0 is emitted, which is what aggregation only
+// requests need
+func (query QueryDoc) WithSize(n int) QueryDoc {
+	query.Size = n
+	query.sizeSet = true
+	return query
+}
+
+// WithFrom returns a copy of the query with From set to n. Like WithSize,
+// a from of 0 is emitted
+func (query QueryDoc) WithFrom(n int) QueryDoc {
+	query.From = n
+	query.fromSet = true
+	return query
+}
+
+// ScopeTo returns a copy of the query with a term filter on field for
+// value appended to its Filter list, eg: to restrict it to a tenant
+func (query QueryDoc) ScopeTo(field string, value interface{}) QueryDoc {
+	return query.AddFilter(QueryItem{
+		Field: field,
+		Value: value,
+		Type:  Term,
+	})
+}
+
+// AddMust returns a copy of the query with items appended to its And list
+func (query QueryDoc) AddMust(items ...QueryItem) QueryDoc {
+	query.And = appendItems(query.And, items)
+	return query
+}
+
+// AddShould returns a copy of the query with items appended to its Or list
+func (query QueryDoc) AddShould(items ...QueryItem) QueryDoc {
+	query.Or = appendItems(query.Or, items)
+	return query
+}
+
+// AddMustNot returns a copy of the query with items appended to its Not list
+func (query QueryDoc) AddMustNot(items ...QueryItem) QueryDoc {
+	query.Not = appendItems(query.Not, items)
+	return query
+}
+
+// AddFilter returns a copy of the query with items appended to its Filter list
+func (query QueryDoc) AddFilter(items ...QueryItem) QueryDoc {
+	query.Filter = appendItems(query.Filter, items)
+	return query
+}
+
+// appendItems appends items to a copy of list, so that the
+// list of the QueryDoc being copied is left untouched
+func appendItems(list []QueryItem, items []QueryItem) []QueryItem {
+	appended := make([]QueryItem, 0, len(list)+len(items))
+	appended = append(appended, list...)
+	return append(appended, items...)
+}
+
+// Reset clears the QueryDoc so it can be reused for another request. Its
+// slices are truncated rather than dropped, keeping their capacity, which
+// makes QueryDocs cheap to recycle through a sync.Pool on hot paths.
+//
+// As the slices are cleared and then appended to in place, Reset must only
+// be called on a QueryDoc whose slices nothing else references. Copies made
+// by value, including the ones returned by WithSize, Page and the like, share
+// their slices with the original QueryDoc, so Clone them before pooling
+func (query *QueryDoc) Reset() {
+	*query = QueryDoc{
+		Sort:        resetSort(query.Sort),
+		SearchAfter: resetValues(query.SearchAfter),
+		And:         resetItems(query.And),
+		Not:         resetItems(query.Not),
+		Or:          resetItems(query.Or),
+		Filter:      resetItems(query.Filter),
+		SortBy:      resetValues(query.SortBy),
+		Fields:      resetValues(query.Fields),
+	}
+}
+
+// Clone returns a copy of the query with its own clause lists, sort,
+// search_after, fields, indices, routing and aggs, so that appending to or
+// resetting the copy leaves the query untouched. The items themselves are
+// shared
+func (query QueryDoc) Clone() QueryDoc {
+	query.Sort = append([]map[string]string(nil), query.Sort...)
+	query.SearchAfter = append([]interface{}(nil), query.SearchAfter...)
+	query.And = append([]QueryItem(nil), query.And...)
+	query.Not = append([]QueryItem(nil), query.Not...)
+	query.Or = append([]QueryItem(nil), query.Or...)
+	query.Filter = append([]QueryItem(nil), query.Filter...)
+	query.SortBy = append([]interface{}(nil), query.SortBy...)
+	query.Fields = append([]interface{}(nil), query.Fields...)
+	query.Indices = append([]string(nil), query.Indices...)
+	query.Routing = append([]string(nil), query.Routing...)
+
+	if query.Aggs != nil {
+		aggs := make(map[string]Agg, len(query.Aggs))
+		for name, agg := range query.Aggs {
+			aggs[name] = agg
+		}
+		query.Aggs = aggs
+	}
+	return query
+}
+
+// resetItems, resetValues and resetSort zero out the elements of a slice,
+// so that a reused QueryDoc doesn't hold on to them, and truncate it
+func resetItems(items []QueryItem) []QueryItem {
+	for i := range items {
+		items[i] = QueryItem{}
+	}
+	return items[:0]
+}
+
+func resetValues(values []interface{}) []interface{} {
+	for i := range values {
+		values[i] = nil
+	}
+	return values[:0]
+}
+
+func resetSort(sort []map[string]string) []map[string]string {
+	for i := range sort {
+		sort[i] = nil
+	}
+	return sort[:0]
+}
+
+// SortField is the object form of a sort clause, used when sorting
+// on a field needs more than just an order. Empty attrs are omitted
+type SortField struct {
+	Field        string
+	Order        string
+	Mode         string
+	Missing      interface{}
+	UnmappedType string
+}
+
+// MarshalJSON will convert the SortField struct into its ES representation
+func (s SortField) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		s.Field: struct {
+			Order        string      `json:"order,omitempty"`
+			Mode         string      `json:"mode,omitempty"`
+			Missing      interface{} `json:"missing,omitempty"`
+			UnmappedType string      `json:"unmapped_type,omitempty"`
+		}{s.Order, s.Mode, s.Missing, s.UnmappedType},
+	})
 }
 
 var _ query = (*QueryDoc)(nil)
@@ -92,11 +442,49 @@ func (query QueryDoc) filterList() []QueryItem {
 	return query.Filter
 }
 
+func (query QueryDoc) boost() *float64 {
+	return query.Boost
+}
+
+func (query QueryDoc) minimumShouldMatch() interface{} {
+	return query.MinimumShouldMatch
+}
+
+func (query QueryDoc) name() string {
+	return query.Name
+}
+
+// Page returns a copy of the query with From and Size set to fetch the
+// given 1-indexed page of size hits. Pages below 1 are treated as page 1
+func (query QueryDoc) Page(page, size int) QueryDoc {
+	if page < 1 {
+		page = 1
+	}
+	query.From = (page - 1) * size
+	query.Size = size
+
+	return query
+}
+
+// NestedQueryItem is used to construct a nested query. The clause lists
+// apply to the nested documents found under the QueryItem's Field attr.
+// Name and Boost, when set, are emitted as the nested query's _name and boost.
+// IgnoreUnmapped should be set when some of the searched indices lack the
+// nested mapping, otherwise ES errors. ScoreMode ("avg", "sum", "min", "max"
+// or "none") controls how matching nested documents score the parent.
+// InnerHits, when set, returns the matching nested documents with each hit.
+// When several nested queries on the same path have inner_hits, the unnamed
+// ones are named path_index (eg: "comments_1") so that ES can tell them apart
 type NestedQueryItem struct {
-	And    []QueryItem
-	Not    []QueryItem
-	Or     []QueryItem
-	Filter []QueryItem
+	And            []QueryItem
+	Not            []QueryItem
+	Or             []QueryItem
+	Filter         []QueryItem
+	Name           string
+	Boost          float64
+	IgnoreUnmapped bool
+	ScoreMode      string
+	InnerHits      *InnerHits
 }
 
 var _ query = (*NestedQueryItem)(nil)
@@ -117,26 +505,416 @@ func (n NestedQueryItem) filterList() []QueryItem {
 	return n.Filter
 }
 
+func (n NestedQueryItem) boost() *float64 {
+	return nil
+}
+
+func (n NestedQueryItem) minimumShouldMatch() interface{} {
+	return nil
+}
+
+func (n NestedQueryItem) name() string {
+	return ""
+}
+
 // HasChildQueryItem is used to construct a has_child query.
 // The Query attr specifies the query that applies to the child documents
-// and the Type attr must be the type name of the child documents
+// and the Type attr must be the type name of the child documents.
+// IgnoreUnmapped should be set when some of the searched indices
+// lack the join mapping, otherwise ES errors
 type HasChildQueryItem struct {
-	Query QueryItem
-	Type  string
+	Query          QueryItem
+	Type           string
+	IgnoreUnmapped bool
+}
+
+// HasParentQueryItem is used to construct a has_parent query.
+// The Query attr specifies the query that applies to the parent documents
+// and the ParentType attr must be the type name of the parent documents.
+// IgnoreUnmapped behaves just like it does for HasChildQueryItem
+type HasParentQueryItem struct {
+	Query          QueryItem
+	ParentType     string
+	IgnoreUnmapped bool
+}
+
+// QueryStringItem is used to construct a query_string query that needs
+// more than a single field. The Query attr is the search term and the Fields
+// attr lists the document attrs to search against. When Fields is empty, the
+// QueryItem's Field attr is used instead, unless DefaultField is set in which
+// case ES decides which fields to search (eg: "*" for all of them).
+// The remaining attrs map onto the ES options of the same name and are only
+// emitted when set. Note that leading wildcards are expensive, so
+// AllowLeadingWildcard should only be turned on when really needed
+type QueryStringItem struct {
+	Query                string
+	Fields               []string
+	DefaultField         string
+	DefaultOperator      string
+	Lenient              bool
+	PhraseSlop           int
+	AllowLeadingWildcard bool
+	Boost                float64
+	Analyzer             string
+	QuoteFieldSuffix     string
+	MinimumShouldMatch   interface{}
+}
+
+// MultiMatchItem is used to construct a multi_match query. The Query attr
+// is the search term and the Fields attr lists the document attrs to search
+// against, falling back to the QueryItem's Field attr when empty. The
+// remaining attrs are only emitted when set
+type MultiMatchItem struct {
+	Query              string
+	Fields             []string
+	MinimumShouldMatch interface{}
+	Type               string
+	Slop               int
+	Boost              float64
 }
 
 // QueryItem is used to construct the specific query type json bodies
 // for example if we want a "match" query, the Type attr should be "Match"
 // the Field attr should be the document attr we want to query against
-// and the Value attr should be the actual search term
+// and the Value attr should be the actual search term. The optional Name
+// attr is emitted as the query's _name, which ES reports back in each
+// hit's matched_queries. For nested wraps and nested queries, a name set on
+// the wrapped query itself takes precedence
 type QueryItem struct {
 	Field string
 	Value interface{}
 	Type  QueryType
+	Name  string
+}
+
+// SortByScore builds a sort clause ordering hits by relevance. Sort
+// clauses are applied in slice order, so to break ties on a field sort
+// by score place it after that field's clause:
+//
+//	Sort: []map[string]string{{"date": "desc"}, SortByScore("desc")}
+func SortByScore(order string) map[string]string {
+	return map[string]string{"_score": order}
+}
+
+// SortByDoc builds a sort clause ordering hits by index order, which is
+// the cheapest sort available and the recommended one for scrolling
+func SortByDoc() map[string]string {
+	return map[string]string{"_doc": "asc"}
 }
 
 // WrapQueryItems is to build nested queries
 func WrapQueryItems(itemType string, items ...QueryItem) QueryItem {
+	return QueryItem{
+		Type:  Nested,
+		Value: wrapQueryDoc(itemType, items),
+	}
+}
+
+// WrapQueryItemsNamed builds a nested query just like WrapQueryItems but
+// also names the wrapped bool query, so that it shows in matched_queries
+func WrapQueryItemsNamed(itemType string, name string, items ...QueryItem) QueryItem {
+	queryDoc := wrapQueryDoc(itemType, items)
+	queryDoc.Name = name
+
+	return QueryItem{
+		Type:  Nested,
+		Value: queryDoc,
+	}
+}
+
+// WrapQueryItemsBoosted builds a nested query just like WrapQueryItems
+// but also sets a boost on the wrapped bool query
+func WrapQueryItemsBoosted(itemType string, boost float64, items ...QueryItem) QueryItem {
+	queryDoc := wrapQueryDoc(itemType, items)
+	queryDoc.Boost = &boost
+
+	return QueryItem{
+		Type:  Nested,
+		Value: queryDoc,
+	}
+}
+
+// Not negates item by wrapping it in a nested must_not query, so that
+// it can be dropped into any clause list
+func Not(item QueryItem) QueryItem {
+	return WrapQueryItems("not", item)
+}
+
+// AnyOf wraps items in a nested should query, matching documents
+// that match at least one of them
+func AnyOf(items ...QueryItem) QueryItem {
+	return WrapQueryItems("or", items...)
+}
+
+// AllOf wraps items in a nested must query, matching documents
+// that match every one of them
+func AllOf(items ...QueryItem) QueryItem {
+	return WrapQueryItems("and", items...)
+}
+
+// AtLeast wraps items in a nested should query that requires at least n
+// of them to match. Unlike AnyOf, the should clauses stay required even
+// when the wrapped query ends up within a must or filter context
+func AtLeast(n int, items ...QueryItem) QueryItem {
+	queryDoc := wrapQueryDoc("or", items)
+	queryDoc.MinimumShouldMatch = n
+
+	return QueryItem{
+		Type:  Nested,
+		Value: queryDoc,
+	}
+}
+
+// AnyPhrase builds a nested should query requiring field
+// to match at least one of phrases
+func AnyPhrase(field string, phrases ...string) QueryItem {
+	items := make([]QueryItem, 0, len(phrases))
+	for _, phrase := range phrases {
+		items = append(items, QueryItem{
+			Field: field,
+			Value: phrase,
+			Type:  MatchPhrase,
+		})
+	}
+
+	return AtLeast(1, items...)
+}
+
+// wildcardEscaper escapes the characters a wildcard query treats specially
+var wildcardEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
+
+// PrefixOrWildcard builds a nested should query matching field values that
+// either start with or contain token, taken literally. Like wildcard values,
+// the token is lowercased for both queries unless SetWildcardLowercase turned
+// that off. An empty token matches nothing
+func PrefixOrWildcard(field, token string) QueryItem {
+	if token == "" {
+		return QueryItem{Type: MatchNone}
+	}
+	if wildcardLowercase {
+		token = strings.ToLower(token)
+	}
+
+	return AtLeast(1,
+		QueryItem{
+			Field: field,
+			Value: token,
+			Type:  Prefix,
+		},
+		QueryItem{
+			Field: field,
+			Value: "*" + wildcardEscaper.Replace(token) + "*",
+			Type:  Wildcard,
+		},
+	)
+}
+
+// LeadingWildcardErr is a custom err returned by SafeWildcard if the
+// pattern starts with a wildcard
+type LeadingWildcardErr struct {
+	Pattern string
+}
+
+func (e *LeadingWildcardErr) Error() string {
+	return fmt.Sprintf("Wildcard pattern %q starts with a wildcard", e.Pattern)
+}
+
+// SafeWildcard builds a Wildcard QueryItem on field out of user input. The
+// * and ? in pattern are kept as wildcards while backslashes are escaped, and
+// patterns starting with a wildcard are rejected with a LeadingWildcardErr
+func SafeWildcard(field, pattern string) (QueryItem, error) {
+	if strings.IndexAny(pattern, "*?") == 0 {
+		return QueryItem{}, &LeadingWildcardErr{Pattern: pattern}
+	}
+
+	return SafeWildcardAllowLeading(field, pattern), nil
+}
+
+// SafeWildcardAllowLeading is like SafeWildcard but accepts patterns
+// starting with a wildcard, which have to scan every term of the field
+func SafeWildcardAllowLeading(field, pattern string) QueryItem {
+	return QueryItem{
+		Field: field,
+		Value: strings.ReplaceAll(pattern, `\`, `\\`),
+		Type:  Wildcard,
+	}
+}
+
+// AllOfAnyOf builds a nested filter query with one group per field, where
+// each group requires the field to match at least one of its values.
+// For example, "serviceName is a or b, AND logKind is x or y" becomes:
+//
+//	AllOfAnyOf(map[string][]interface{}{
+//	    "serviceName": {"a", "b"},
+//	    "logKind":     {"x", "y"},
+//	})
+//
+// Groups are emitted sorted by field name so the output is stable
+func AllOfAnyOf(groups map[string][]interface{}) QueryItem {
+	fields := make([]string, 0, len(groups))
+	for field := range groups {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	filters := make([]QueryItem, 0, len(fields))
+	for _, field := range fields {
+		matches := make([]QueryItem, 0, len(groups[field]))
+		for _, value := range groups[field] {
+			matches = append(matches, QueryItem{
+				Field: field,
+				Value: value,
+				Type:  Match,
+			})
+		}
+		filters = append(filters, AtLeast(1, matches...))
+	}
+
+	return WrapQueryItems("filter", filters...)
+}
+
+// TermsOrMissing matches documents where field is one of values
+// or where field is missing altogether
+func TermsOrMissing(field string, values []string) QueryItem {
+	return AtLeast(1,
+		QueryItem{
+			Field: field,
+			Value: values,
+			Type:  Terms,
+		},
+		Not(QueryItem{
+			Field: "field",
+			Value: field,
+			Type:  Exists,
+		}),
+	)
+}
+
+// IDsInIndex matches the documents of index whose _id is one of ids,
+// which is needed when fetching by id across several indices
+func IDsInIndex(index string, ids []string) QueryItem {
+	return WrapQueryItems("filter",
+		QueryItem{
+			Value: ids,
+			Type:  IDs,
+		},
+		QueryItem{
+			Field: "_index",
+			Value: index,
+			Type:  Term,
+		},
+	)
+}
+
+// TermsOf builds a Terms QueryItem matching field against values,
+// for any slice of comparable values
+func TermsOf[T comparable](field string, values []T) QueryItem {
+	terms := make([]interface{}, len(values))
+	for i, value := range values {
+		terms[i] = value
+	}
+
+	return QueryItem{
+		Field: field,
+		Value: terms,
+		Type:  Terms,
+	}
+}
+
+// FuzzyMatch builds a match query on field allowing query terms to be
+// up to fuzziness edits (eg: "AUTO", "1") away from indexed terms
+func FuzzyMatch(field, query string, fuzziness string) QueryItem {
+	return QueryItem{
+		Field: field,
+		Value: map[string]interface{}{
+			"query":     query,
+			"fuzziness": fuzziness,
+		},
+		Type: Match,
+	}
+}
+
+// MultiMatchFields builds a multi_match query searching query across fields
+func MultiMatchFields(query string, fields []string) QueryItem {
+	return QueryItem{
+		Type: MultiMatch,
+		Value: MultiMatchItem{
+			Query:  query,
+			Fields: fields,
+		},
+	}
+}
+
+// MultiMatchBoosted builds a multi_match query searching query across the
+// fields, each weighted by its boost using the "field^boost" syntax. A boost
+// of 1 is left off. Fields are emitted from highest to lowest boost, and by
+// name for equal boosts, so the output is stable
+func MultiMatchBoosted(query string, fields map[string]float64) QueryItem {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if fields[names[i]] != fields[names[j]] {
+			return fields[names[i]] > fields[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	boosted := make([]string, 0, len(names))
+	for _, name := range names {
+		if boost := fields[name]; boost != 1 {
+			name += "^" + strconv.FormatFloat(boost, 'f', -1, 64)
+		}
+		boosted = append(boosted, name)
+	}
+
+	return MultiMatchFields(query, boosted)
+}
+
+// MultiMatchPhrase builds a phrase multi_match query, matching query as a
+// phrase in any of the fields while allowing up to slop positions between terms
+func MultiMatchPhrase(query string, fields []string, slop int) QueryItem {
+	return QueryItem{
+		Type: MultiMatch,
+		Value: MultiMatchItem{
+			Query:  query,
+			Fields: fields,
+			Type:   "phrase",
+			Slop:   slop,
+		},
+	}
+}
+
+// FilterContext wraps items in a nested filter query. Items in filter
+// context only decide whether a document matches and are never scored,
+// which also makes them cacheable
+func FilterContext(items ...QueryItem) QueryItem {
+	return WrapQueryItems("filter", items...)
+}
+
+// QueryContext wraps items in a nested must query. Items in query
+// context both decide whether a document matches and score it
+func QueryContext(items ...QueryItem) QueryItem {
+	return WrapQueryItems("and", items...)
+}
+
+// AllExist builds a nested filter query matching documents
+// in which every one of the fields exists
+func AllExist(fields ...string) QueryItem {
+	items := make([]QueryItem, 0, len(fields))
+	for _, field := range fields {
+		items = append(items, QueryItem{
+			Field: "field",
+			Value: field,
+			Type:  Exists,
+		})
+	}
+
+	return WrapQueryItems("filter", items...)
+}
+
+func wrapQueryDoc(itemType string, items []QueryItem) QueryDoc {
 	queryDoc := QueryDoc{}
 	switch strings.ToLower(itemType) {
 	case "or":
@@ -145,14 +923,14 @@ func WrapQueryItems(itemType string, items ...QueryItem) QueryItem {
 		queryDoc.Not = items
 	case "filter":
 		queryDoc.Filter = items
+	case "and":
+		queryDoc.And = items
 	default:
 		queryDoc.And = items
+		queryDoc.unknownClause = itemType
 	}
 
-	return QueryItem{
-		Type:  Nested,
-		Value: queryDoc,
-	}
+	return queryDoc
 }
 
 // Builds a JSON string as follows:
@@ -167,11 +945,23 @@ func WrapQueryItems(itemType string, items ...QueryItem) QueryItem {
 //	    }
 //	}
 type queryReqDoc struct {
-	Query       queryWrap           `json:"query,omitempty"`
-	Size        int                 `json:"size,omitempty"`
-	From        int                 `json:"from,omitempty"`
-	Sort        []map[string]string `json:"sort,omitempty"`
-	SearchAfter []interface{}       `json:"search_after,omitempty"`
+	Query       *queryWrap     `json:"query,omitempty"`
+	Size        *int           `json:"size,omitempty"`
+	From        *int           `json:"from,omitempty"`
+	Sort        interface{}    `json:"sort,omitempty"`
+	SearchAfter []interface{}  `json:"search_after,omitempty"`
+	Aggs        map[string]Agg `json:"aggs,omitempty"`
+	Collapse    *Collapse      `json:"collapse,omitempty"`
+	Source      interface{}    `json:"_source,omitempty"`
+
+	Version          bool `json:"version,omitempty"`
+	SeqNoPrimaryTerm bool `json:"seq_no_primary_term,omitempty"`
+	TerminateAfter   int  `json:"terminate_after,omitempty"`
+
+	Fields  []interface{} `json:"fields,omitempty"`
+	Profile bool          `json:"profile,omitempty"`
+
+	TrackScores bool `json:"track_scores,omitempty"`
 }
 
 type queryWrap struct {
@@ -183,20 +973,160 @@ type boolWrap struct {
 	NotList    []leafQuery `json:"must_not,omitempty"`
 	OrList     []leafQuery `json:"should,omitempty"`
 	FilterList []leafQuery `json:"filter,omitempty"`
+	Boost      *float64    `json:"boost,omitempty"`
+
+	MinimumShouldMatch interface{} `json:"minimum_should_match,omitempty"`
+	Name               string      `json:"_name,omitempty"`
+}
+
+func (b boolWrap) isEmpty() bool {
+	return len(b.AndList) == 0 && len(b.NotList) == 0 && len(b.OrList) == 0 &&
+		len(b.FilterList) == 0 && b.Boost == nil && b.MinimumShouldMatch == nil &&
+		b.Name == ""
 }
 
 type leafQuery struct {
-	Type  QueryType
-	Name  string
+	Type      QueryType
+	Name      string
+	Value     interface{}
+	QueryName string
+}
+
+// These leaf query types key an object by the queried field, so their
+// _name and boost have to go within that object, expanding the value
+var fieldObjectTypes = map[QueryType]bool{
+	Match:       true,
+	MatchPhrase: true,
+	Term:        true,
+	Wildcard:    true,
+	Prefix:      true,
+	Regexp:      true,
+	Range:       true,
+}
+
+// BoostValue is used as a QueryItem's Value to give a leaf query a boost.
+// Field keyed queries such as term, wildcard and prefix get expanded into
+// their {"value": ..., "boost": ...} object form, while others such as
+// exists, ids and multi_match get the boost next to their options. For
+// match_all and match_none, Boosted(nil, boost) is enough
+type BoostValue struct {
 	Value interface{}
+	Boost float64
+}
+
+// Boosted wraps value so that the query it is used in carries boost
+func Boosted(value interface{}, boost float64) BoostValue {
+	return BoostValue{Value: value, Boost: boost}
+}
+
+// unboost splits a BoostValue into the value it wraps and itself, and
+// reports whether value was one. Other values are returned as is
+func unboost(value interface{}) (interface{}, BoostValue, bool) {
+	boosted, ok := value.(BoostValue)
+	if !ok {
+		return value, BoostValue{}, false
+	}
+	return boosted.Value, boosted, true
+}
+
+// leafBody builds the body of a leaf query, which is keyed by field name
+func (q leafQuery) leafBody() (map[string]interface{}, error) {
+	value := q.Value
+	boosted, isBoosted := q.Value.(BoostValue)
+	if isBoosted {
+		value = boosted.Value
+	}
+
+	if q.QueryName == "" && !isBoosted {
+		return map[string]interface{}{q.Name: value}, nil
+	}
+
+	// the options go next to the field unless the query keys an object by it
+	body := map[string]interface{}{q.Name: value}
+	target := body
+	if fieldObjectTypes[q.Type] {
+		object, err := objectValue(q.Type, value)
+		if err != nil {
+			return nil, err
+		}
+		body[q.Name] = object
+		target = object
+	}
+
+	if q.QueryName != "" {
+		target["_name"] = q.QueryName
+	}
+	if isBoosted {
+		target["boost"] = boosted.Boost
+	}
+
+	return body, nil
+}
+
+// objectValue returns value in its object form, eg: "kimchy" becomes
+// {"query": "kimchy"} for a match query and {"value": "kimchy"} for a term
+// query. Values that already are objects are copied over
+func objectValue(queryType QueryType, value interface{}) (map[string]interface{}, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(value)+1)
+		for key, val := range value {
+			object[key] = val
+		}
+		return object, nil
+	case map[string]string:
+		object := make(map[string]interface{}, len(value)+1)
+		for key, val := range value {
+			object[key] = val
+		}
+		return object, nil
+	}
+
+	if kind := reflect.Indirect(reflect.ValueOf(value)).Kind(); kind == reflect.Map || kind == reflect.Struct {
+		body, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		object := make(map[string]interface{})
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			return nil, err
+		}
+		return object, nil
+	}
+
+	valueKey := "value"
+	if queryType == Match || queryType == MatchPhrase {
+		valueKey = "query"
+	}
+	return map[string]interface{}{valueKey: value}, nil
+}
+
+// wildcardLowercase controls whether wildcard query values are lowercased
+var wildcardLowercase = true
+
+// SetWildcardLowercase toggles the lowercasing of wildcard query values,
+// which is on by default. Turn it off when querying mixed case keyword
+// fields. It is not safe to call while queries are being marshaled, so
+// call it during setup
+func SetWildcardLowercase(lowercase bool) {
+	wildcardLowercase = lowercase
 }
 
 func (q leafQuery) handleMarshalType(queryType string) ([]byte, error) {
 	// lowercase wildcard queries
-	if q.Type == Wildcard {
+	if q.Type == Wildcard && wildcardLowercase {
 		if s, ok := q.Value.(string); ok {
 			q.Value = strings.ToLower(s)
 		}
+		if boosted, ok := q.Value.(BoostValue); ok {
+			if s, ok := boosted.Value.(string); ok {
+				boosted.Value = strings.ToLower(s)
+				q.Value = boosted
+			}
+		}
 	}
 
 	if q.Type == QueryString {
@@ -211,20 +1141,103 @@ func (q leafQuery) handleMarshalType(queryType string) ([]byte, error) {
 		return q.handleHasChild()
 	}
 
+	if q.Type == HasParent {
+		return q.handleHasParent()
+	}
+
+	if q.Type == MatchAll || q.Type == MatchNone {
+		return q.handleMatchAll(queryType)
+	}
+
+	if q.Type == IDs {
+		return q.handleIDs()
+	}
+
+	if q.Type == FunctionScore {
+		return q.handleFunctionScore()
+	}
+
+	if q.Type == MultiMatch {
+		return q.handleMultiMatch()
+	}
+
+	body, err := q.leafBody()
+	if err != nil {
+		return nil, err
+	}
+
 	return json.Marshal(map[string]interface{}{
-		(queryType): map[string]interface{}{
-			(q.Name): q.Value,
-		},
+		(queryType): body,
 	})
 }
 
 func (q leafQuery) handleMarshalQueryString(queryType string) ([]byte, error) {
+	value, boosted, isBoosted := unboost(q.Value)
+	item, ok := value.(QueryStringItem)
+	if !ok {
+		query, ok := value.(string)
+		if !ok {
+			return nil, &ValueTypeErr{Type: QueryString, Got: fmt.Sprintf("%T", value)}
+		}
+		item = QueryStringItem{Query: query}
+	}
+	if isBoosted {
+		item.Boost = boosted.Boost
+	}
+
+	body := map[string]interface{}{
+		"query":            sanitizeElasticQueryField(item.Query),
+		"analyze_wildcard": true, // TODO: make this configurable
+	}
+
+	if item.DefaultField != "" {
+		body["default_field"] = item.DefaultField
+	}
+
+	if item.DefaultOperator != "" {
+		body["default_operator"] = item.DefaultOperator
+	}
+
+	if item.Lenient {
+		body["lenient"] = true
+	}
+
+	if item.PhraseSlop > 0 {
+		body["phrase_slop"] = item.PhraseSlop
+	}
+
+	if item.AllowLeadingWildcard {
+		body["allow_leading_wildcard"] = true
+	}
+
+	if item.Boost != 0 {
+		body["boost"] = item.Boost
+	}
+
+	if item.Analyzer != "" {
+		body["analyzer"] = item.Analyzer
+	}
+
+	if item.QuoteFieldSuffix != "" {
+		body["quote_field_suffix"] = item.QuoteFieldSuffix
+	}
+
+	if item.MinimumShouldMatch != nil {
+		body["minimum_should_match"] = item.MinimumShouldMatch
+	}
+
+	if len(item.Fields) > 0 {
+		body["fields"] = item.Fields
+	} else if item.DefaultField == "" {
+		body["fields"] = []string{q.Name}
+	}
+
+	if q.QueryName != "" {
+		body["_name"] = q.QueryName
+	}
+
 	return json.Marshal(map[string]interface{}{
-		queryType: map[string]interface{}{
-			"fields":           []string{q.Name},
-			"query":            sanitizeElasticQueryField(q.Value.(string)),
-			"analyze_wildcard": true, // TODO: make this configurable
-		},
+		queryType: body,
 	})
 }
 
@@ -234,11 +1247,35 @@ func (q leafQuery) handleMarshalNestedQuery() ([]byte, error) {
 		return nil, &QueryTypeErr{typeVal: NestedQuery}
 	}
 
+	body := map[string]interface{}{
+		"path":  []string{q.Name},
+		"query": getWrappedQuery(item),
+	}
+
+	if item.Name != "" {
+		body["_name"] = item.Name
+	} else if q.QueryName != "" {
+		body["_name"] = q.QueryName
+	}
+
+	if item.Boost != 0 {
+		body["boost"] = item.Boost
+	}
+
+	if item.IgnoreUnmapped {
+		body["ignore_unmapped"] = true
+	}
+
+	if item.ScoreMode != "" {
+		body["score_mode"] = item.ScoreMode
+	}
+
+	if item.InnerHits != nil {
+		body["inner_hits"] = item.InnerHits
+	}
+
 	return json.Marshal(map[string]interface{}{
-		"nested": map[string]interface{}{
-			"path":  []string{q.Name},
-			"query": getWrappedQuery(item),
-		},
+		"nested": body,
 	})
 }
 
@@ -250,23 +1287,210 @@ func (q leafQuery) handleHasChild() ([]byte, error) {
 
 	doc, ok := item.Query.Value.(QueryDoc)
 	if !ok {
-		return nil, errors.New(fmt.Sprintf("invalid value for HasChild query: %v", item.Query.Value))
+		return nil, &ValueTypeErr{Type: HasChild, Got: fmt.Sprintf("%T", item.Query.Value)}
+	}
+	wrapped, err := unwrapQueryDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"query": wrapped,
+		"type":  item.Type,
+	}
+
+	if item.IgnoreUnmapped {
+		body["ignore_unmapped"] = true
+	}
+
+	if q.QueryName != "" {
+		body["_name"] = q.QueryName
 	}
-	wrapped := getWrappedQuery(doc)
 
 	return json.Marshal(map[string]interface{}{
-		"has_child": map[string]interface{}{
-			"query": wrapped,
-			"type":  item.Type,
-		},
+		"has_child": body,
 	})
 }
 
+func (q leafQuery) handleHasParent() ([]byte, error) {
+	item, ok := q.Value.(HasParentQueryItem)
+	if !ok {
+		return nil, &QueryTypeErr{typeVal: HasParent}
+	}
+
+	doc, ok := item.Query.Value.(QueryDoc)
+	if !ok {
+		return nil, &ValueTypeErr{Type: HasParent, Got: fmt.Sprintf("%T", item.Query.Value)}
+	}
+	wrapped, err := unwrapQueryDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"query":       wrapped,
+		"parent_type": item.ParentType,
+	}
+
+	if item.IgnoreUnmapped {
+		body["ignore_unmapped"] = true
+	}
+
+	if q.QueryName != "" {
+		body["_name"] = q.QueryName
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"has_parent": body,
+	})
+}
+
+func (q leafQuery) handleMultiMatch() ([]byte, error) {
+	value, boosted, isBoosted := unboost(q.Value)
+	item, ok := value.(MultiMatchItem)
+	if !ok {
+		query, ok := value.(string)
+		if !ok {
+			return nil, &ValueTypeErr{Type: MultiMatch, Got: fmt.Sprintf("%T", value)}
+		}
+		item = MultiMatchItem{Query: query}
+	}
+	if isBoosted {
+		item.Boost = boosted.Boost
+	}
+
+	fields := item.Fields
+	if len(fields) == 0 {
+		fields = []string{q.Name}
+	}
+
+	body := map[string]interface{}{
+		"query":  item.Query,
+		"fields": fields,
+	}
+
+	if item.MinimumShouldMatch != nil {
+		body["minimum_should_match"] = item.MinimumShouldMatch
+	}
+
+	if item.Type != "" {
+		body["type"] = item.Type
+	}
+
+	if item.Slop > 0 {
+		body["slop"] = item.Slop
+	}
+
+	if item.Boost != 0 {
+		body["boost"] = item.Boost
+	}
+
+	if q.QueryName != "" {
+		body["_name"] = q.QueryName
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"multi_match": body,
+	})
+}
+
+func (q leafQuery) handleIDs() ([]byte, error) {
+	value, boosted, isBoosted := unboost(q.Value)
+	body := map[string]interface{}{
+		"values": value,
+	}
+
+	if isBoosted {
+		body["boost"] = boosted.Boost
+	}
+
+	if q.QueryName != "" {
+		body["_name"] = q.QueryName
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"ids": body,
+	})
+}
+
+func (q leafQuery) handleMatchAll(queryType string) ([]byte, error) {
+	body := map[string]interface{}{}
+
+	if _, boosted, isBoosted := unboost(q.Value); isBoosted {
+		body["boost"] = boosted.Boost
+	}
+
+	if q.QueryName != "" {
+		body["_name"] = q.QueryName
+	}
+
+	return json.Marshal(map[string]interface{}{
+		queryType: body,
+	})
+}
+
+// validateValue checks that the Go type of the value is one that the
+// query type knows how to marshal. Types without specific requirements
+// accept any value
+func (q leafQuery) validateValue() error {
+	value := q.Value
+	if boosted, ok := value.(BoostValue); ok {
+		value = boosted.Value
+	}
+
+	switch q.Type {
+	case Terms:
+		// a list of terms or a terms lookup object
+		switch value.(type) {
+		case map[string]interface{}, map[string]string:
+			return nil
+		}
+		if kind := reflect.ValueOf(value).Kind(); kind == reflect.Slice || kind == reflect.Array {
+			return nil
+		}
+	case Range:
+		kind := reflect.Indirect(reflect.ValueOf(value)).Kind()
+		if kind == reflect.Map || kind == reflect.Struct {
+			return nil
+		}
+	case IDs:
+		// json.Number keeps large numeric ids from losing precision
+		switch value.(type) {
+		case []string, []json.Number:
+			return nil
+		}
+	case Match, MatchPhrase, Term, Wildcard, Prefix, Regexp, Exists:
+		// a scalar or an object form, only checked in StrictMode. Types
+		// marshaling themselves (eg: time.Time) are trusted to be scalars
+		if !StrictMode {
+			return nil
+		}
+		switch value.(type) {
+		case json.Marshaler, encoding.TextMarshaler:
+			return nil
+		}
+		switch reflect.ValueOf(value).Kind() {
+		case reflect.String, reflect.Bool, reflect.Map,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	return &ValueTypeErr{Type: q.Type, Got: fmt.Sprintf("%T", value)}
+}
+
 type query interface {
 	andList() []QueryItem
 	notList() []QueryItem
 	orList() []QueryItem
 	filterList() []QueryItem
+	boost() *float64
+	minimumShouldMatch() interface{}
+	name() string
 }
 
 func getWrappedQuery(query query) queryWrap {
@@ -283,12 +1507,35 @@ func getWrappedQuery(query query) queryWrap {
 	if filter := query.filterList(); len(filter) > 0 {
 		boolDoc.FilterList = updateList(filter)
 	}
+	boolDoc.Boost = query.boost()
+	boolDoc.MinimumShouldMatch = query.minimumShouldMatch()
+	boolDoc.Name = query.name()
 	return queryWrap{Bool: boolDoc}
 }
 
+// unwrapQueryDoc is getWrappedQuery for a QueryDoc that was wrapped into a
+// QueryItem, which in StrictMode must have used a known clause name
+func unwrapQueryDoc(doc QueryDoc) (queryWrap, error) {
+	if StrictMode && doc.unknownClause != "" {
+		return queryWrap{}, &ClauseErr{Clause: doc.unknownClause}
+	}
+	return getWrappedQuery(doc), nil
+}
+
 func (q leafQuery) MarshalJSON() ([]byte, error) {
 	if q.Type == Nested {
-		return json.Marshal(getWrappedQuery(q.Value.(QueryDoc)))
+		queryDoc, ok := q.Value.(QueryDoc)
+		if !ok {
+			return nil, &ValueTypeErr{Type: Nested, Got: fmt.Sprintf("%T", q.Value)}
+		}
+		wrapped, err := unwrapQueryDoc(queryDoc)
+		if err != nil {
+			return nil, err
+		}
+		if wrapped.Bool.Name == "" {
+			wrapped.Bool.Name = q.QueryName
+		}
+		return json.Marshal(wrapped)
 	}
 
 	var queryType string
@@ -297,30 +1544,86 @@ func (q leafQuery) MarshalJSON() ([]byte, error) {
 		return []byte(""), err
 	}
 
+	if fieldRequired[q.Type] && q.Name == "" {
+		return nil, &MissingFieldErr{typeVal: q.Type}
+	}
+
+	if err := q.validateValue(); err != nil {
+		return nil, err
+	}
+
 	return q.handleMarshalType(queryType)
 }
 
 func updateList(queryItems []QueryItem) []leafQuery {
 	leafQueries := make([]leafQuery, 0)
 	for _, item := range queryItems {
-		leafQueries = append(leafQueries, leafQuery{
-			Type:  item.Type,
-			Name:  item.Field,
-			Value: item.Value,
-		})
+		leafQueries = append(leafQueries, toLeafQuery(item))
 	}
 	return leafQueries
 }
 
+// MarshalItems marshals items into the JSON array of their queries, as
+// they would appear within a bool clause, for embedding in other bodies
+func MarshalItems(items []QueryItem) ([]byte, error) {
+	return json.Marshal(updateList(items))
+}
+
+func toLeafQuery(item QueryItem) leafQuery {
+	return leafQuery{
+		Type:      item.Type,
+		Name:      item.Field,
+		Value:     item.Value,
+		QueryName: item.Name,
+	}
+}
+
+// queryItem is the inverse of toLeafQuery
+func (q leafQuery) queryItem() QueryItem {
+	return QueryItem{
+		Field: q.Name,
+		Value: q.Value,
+		Type:  q.Type,
+		Name:  q.QueryName,
+	}
+}
+
 // MarshalJSON will convert QueryDoc struct into
 // valid and spec compliant JSON representation
 func (query QueryDoc) MarshalJSON() ([]byte, error) {
+	query = nameInnerHits(query)
 	queryReq := queryReqDoc{
-		Query:       getWrappedQuery(query),
-		Size:        query.Size,
-		From:        query.From,
-		Sort:        query.Sort,
 		SearchAfter: query.SearchAfter,
+		Aggs:        query.Aggs,
+		Collapse:    query.Collapse,
+		Source:      query.Source,
+
+		Version:          query.Version,
+		SeqNoPrimaryTerm: query.SeqNoPrimaryTerm,
+		TerminateAfter:   query.TerminateAfter,
+
+		Fields:  query.Fields,
+		Profile: query.Profile,
+
+		TrackScores: query.TrackScores,
+	}
+	// aggregation only requests have no clauses, in which case
+	// the query is left out entirely rather than sent empty
+	if wrapped := getWrappedQuery(query); !wrapped.Bool.isEmpty() {
+		queryReq.Query = &wrapped
+	}
+	if query.Size != 0 || query.sizeSet {
+		size := query.Size
+		queryReq.Size = &size
+	}
+	if query.From != 0 || query.fromSet {
+		from := query.From
+		queryReq.From = &from
+	}
+	if len(query.SortBy) > 0 {
+		queryReq.Sort = query.SortBy
+	} else if len(query.Sort) > 0 {
+		queryReq.Sort = query.Sort
 	}
 
 	requestBody, err := json.Marshal(queryReq)
@@ -331,21 +1634,172 @@ func (query QueryDoc) MarshalJSON() ([]byte, error) {
 	return requestBody, nil
 }
 
+// MarshalIndent is like MarshalJSON but indents the output, which is
+// handy for logging and test fixtures. Unlike MarshalJSON, characters
+// such as <, > and & are not escaped
+func (query QueryDoc) MarshalIndent(prefix, indent string) ([]byte, error) {
+	body, err := query.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, unescapeHTML(body), prefix, indent); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// htmlEscapes are the escape sequences json.Marshal uses for HTML
+// sensitive characters
+var htmlEscapes = map[string]byte{
+	`\u003c`: '<',
+	`\u003e`: '>',
+	`\u0026`: '&',
+}
+
+// EqualJSON reports whether a and b hold the same JSON value, regardless
+// of key order and whitespace. It is handy for comparing a marshaled QueryDoc
+// against a fixture. Numbers are compared exactly rather than as float64s,
+// so large ids don't collide. Invalid JSON is never equal to anything
+func EqualJSON(a, b []byte) bool {
+	valueA, err := decodeJSON(a)
+	if err != nil {
+		return false
+	}
+	valueB, err := decodeJSON(b)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(valueA, valueB)
+}
+
+// decodeJSON decodes body with every number turned into the canonical
+// string of its exact value, so that 1 and 1.0 still compare equal
+func decodeJSON(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	return canonicalNumbers(value), nil
+}
+
+func canonicalNumbers(value interface{}) interface{} {
+	switch value := value.(type) {
+	case json.Number:
+		number, ok := new(big.Rat).SetString(value.String())
+		if !ok {
+			return value
+		}
+		return json.Number(number.RatString())
+	case map[string]interface{}:
+		for key, elem := range value {
+			value[key] = canonicalNumbers(elem)
+		}
+	case []interface{}:
+		for i, elem := range value {
+			value[i] = canonicalNumbers(elem)
+		}
+	}
+	return value
+}
+
+// unescapeHTML reverts the HTML escaping json.Marshal applies to strings.
+// Escape sequences are walked one at a time so that an escaped backslash
+// followed by "u003c" is left alone
+func unescapeHTML(body []byte) []byte {
+	unescaped := make([]byte, 0, len(body))
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' || i+1 >= len(body) {
+			unescaped = append(unescaped, body[i])
+			continue
+		}
+
+		if i+6 <= len(body) {
+			if char, ok := htmlEscapes[string(body[i:i+6])]; ok {
+				unescaped = append(unescaped, char)
+				i += 5
+				continue
+			}
+		}
+
+		unescaped = append(unescaped, body[i], body[i+1])
+		i++
+	}
+	return unescaped
+}
+
+// msearchHeader is the header line written ahead of each query body.
+// An empty index is omitted so that ES falls back to the URL's index
+type msearchHeader struct {
+	Index             string `json:"index,omitempty"`
+	IgnoreUnavailable bool   `json:"ignore_unavailable,omitempty"`
+	AllowNoIndices    *bool  `json:"allow_no_indices,omitempty"`
+	ExpandWildcards   string `json:"expand_wildcards,omitempty"`
+	SearchType        string `json:"search_type,omitempty"`
+	Routing           string `json:"routing,omitempty"`
+}
+
 // MultiSearchDoc constructs document format for multisearch functionality using Query DSL
 func MultiSearchDoc(queries []QueryDoc) (string, error) {
 	var requestBuilder strings.Builder
 	for _, query := range queries {
+		index := query.Index
+		if len(query.Indices) > 0 {
+			index = strings.Join(query.Indices, ",")
+		}
+		header, err := json.Marshal(msearchHeader{
+			Index:             index,
+			IgnoreUnavailable: query.IgnoreUnavailable,
+			AllowNoIndices:    query.AllowNoIndices,
+			ExpandWildcards:   query.ExpandWildcards,
+			SearchType:        query.SearchType,
+			Routing:           strings.Join(query.Routing, ","),
+		})
+		if err != nil {
+			return "", err
+		}
 		body, err := json.Marshal(query)
 		if err != nil {
 			return "", err
 		}
-		requestBuilder.WriteString(fmt.Sprintf(`{"index":"%s"}`, query.Index) + "\n")
+		requestBuilder.WriteString(string(header) + "\n")
 		requestBuilder.WriteString(string(body) + "\n")
 	}
 
 	return requestBuilder.String(), nil
 }
 
+// IndicesOf returns the distinct indices the queries target, in the order
+// they first appear, taking Indices over Index like MultiSearchDoc does.
+// Queries without an index are skipped
+func IndicesOf(queries []QueryDoc) []string {
+	indices := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, query := range queries {
+		targets := query.Indices
+		if len(targets) == 0 {
+			targets = []string{query.Index}
+		}
+		for _, index := range targets {
+			if index == "" || seen[index] {
+				continue
+			}
+			seen[index] = true
+			indices = append(indices, index)
+		}
+	}
+
+	return indices
+}
+
 // Elasticsearch defines a set of "reserved keywords" that MUST be escaped
 // in order to be queryable. More info can be found in the docs:
 // BASE: https://www.elastic.co/guide/en/elasticsearch/reference/current ...
@@ -3,7 +3,10 @@ package esquerydsl
 import (
 	"encoding/json"
 	"errors"
+	"net"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestBogusQueryType(t *testing.T) {
@@ -25,6 +28,337 @@ func TestBogusQueryType(t *testing.T) {
 	}
 }
 
+func TestMissingField(t *testing.T) {
+	_, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Value: "some-value",
+				Type:  Match,
+			},
+		},
+	})
+
+	var missingFieldErr *MissingFieldErr
+	if !errors.As(err, &missingFieldErr) {
+		t.Fatalf("\nUnexpected error: %v", err)
+	}
+
+	expected := "Type match requires a Field"
+	if missingFieldErr.Error() != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, missingFieldErr.Error())
+	}
+}
+
+func TestTermsQueryValues(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{[]int{1, 2, 3}, `{"query":{"bool":{"filter":[{"terms":{"id":[1,2,3]}}]}}}`},
+		{[]int64{1, 2, 3}, `{"query":{"bool":{"filter":[{"terms":{"id":[1,2,3]}}]}}}`},
+		{[]float64{1.5, 2}, `{"query":{"bool":{"filter":[{"terms":{"id":[1.5,2]}}]}}}`},
+		{[]interface{}{1, "2", true}, `{"query":{"bool":{"filter":[{"terms":{"id":[1,"2",true]}}]}}}`},
+		{[]int32{1, 2}, `{"query":{"bool":{"filter":[{"terms":{"id":[1,2]}}]}}}`},
+		{[]uint64{1, 2}, `{"query":{"bool":{"filter":[{"terms":{"id":[1,2]}}]}}}`},
+		{[]float32{1.5, 2}, `{"query":{"bool":{"filter":[{"terms":{"id":[1.5,2]}}]}}}`},
+		{[2]string{"1", "2"}, `{"query":{"bool":{"filter":[{"terms":{"id":["1","2"]}}]}}}`},
+		{[]bool{true}, `{"query":{"bool":{"filter":[{"terms":{"id":[true]}}]}}}`},
+	}
+
+	for _, test := range tests {
+		body, err := json.Marshal(QueryDoc{
+			Index: "some_index",
+			Filter: []QueryItem{
+				{
+					Field: "id",
+					Value: test.value,
+					Type:  Terms,
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		if string(body) != test.expected {
+			t.Errorf("\nWant: %q\nHave: %q", test.expected, string(body))
+		}
+	}
+}
+
+func TestBooleanTermsQuery(t *testing.T) {
+	body, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Filter: []QueryItem{
+			{Field: "published", Value: []bool{true, false}, Type: Terms},
+			TermsOf("archived", []bool{false}),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"filter":[{"terms":{"published":[true,false]}},{"terms":{"archived":[false]}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestValueTypeMismatch(t *testing.T) {
+	items := []QueryItem{
+		{Field: "id", Value: "b4ab2c6e", Type: Terms},
+		{Field: "publish_date", Value: "2015-01-01", Type: Range},
+		{Value: "b4ab2c6e", Type: IDs},
+	}
+
+	for _, item := range items {
+		_, err := json.Marshal(QueryDoc{
+			Index: "some_index",
+			And:   []QueryItem{item},
+		})
+
+		var valueTypeErr *ValueTypeErr
+		if !errors.As(err, &valueTypeErr) {
+			t.Errorf("\nUnexpected error: %v", err)
+			continue
+		}
+		if valueTypeErr.Type != item.Type || valueTypeErr.Got != "string" {
+			t.Errorf("\nUnexpected error: %v", valueTypeErr)
+		}
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	unknownClause := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			WrapQueryItems("should",
+				QueryItem{Field: "tags", Value: "featured", Type: Term},
+			),
+		},
+	}
+	wrongValue := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{Field: "tags", Value: []string{"featured", "pinned"}, Type: Term},
+		},
+	}
+
+	if _, err := json.Marshal(unknownClause); err != nil {
+		t.Errorf("\nUnexpected error: %v", err)
+	}
+	if _, err := json.Marshal(wrongValue); err != nil {
+		t.Errorf("\nUnexpected error: %v", err)
+	}
+
+	defer func(strict bool) { StrictMode = strict }(StrictMode)
+	StrictMode = true
+
+	_, err := json.Marshal(unknownClause)
+	var clauseErr *ClauseErr
+	if !errors.As(err, &clauseErr) || clauseErr.Clause != "should" {
+		t.Errorf("\nUnexpected error: %v", err)
+	}
+
+	_, err = json.Marshal(wrongValue)
+	var valueTypeErr *ValueTypeErr
+	if !errors.As(err, &valueTypeErr) || valueTypeErr.Type != Term || valueTypeErr.Got != "[]string" {
+		t.Errorf("\nUnexpected error: %v", err)
+	}
+
+	body, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			WrapQueryItems("AND",
+				QueryItem{Field: "tags", Value: Boosted("featured", 2), Type: Term},
+				QueryItem{Field: "title", Value: map[string]interface{}{"query": "Search"}, Type: Match},
+				QueryItem{Field: "created_at", Value: time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC), Type: Term},
+				QueryItem{Field: "ip", Value: net.ParseIP("10.0.0.1"), Type: Term},
+			),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"must":[{"bool":{"must":[{"term":{"tags":{"boost":2,"value":"featured"}}},{"match":{"title":{"query":"Search"}}},{"term":{"created_at":"2020-03-01T00:00:00Z"}},{"term":{"ip":"10.0.0.1"}}]}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestStrictModeJoinQueries(t *testing.T) {
+	defer func(strict bool) { StrictMode = strict }(StrictMode)
+	StrictMode = true
+
+	bogus := WrapQueryItems("bogus", QueryItem{Field: "tag", Value: "go", Type: Term})
+	items := []QueryItem{
+		{Value: HasChildQueryItem{Type: "child", Query: bogus}, Type: HasChild},
+		{Value: HasParentQueryItem{ParentType: "parent", Query: bogus}, Type: HasParent},
+	}
+
+	for _, item := range items {
+		_, err := json.Marshal(QueryDoc{
+			Index: "some_index",
+			And:   []QueryItem{item},
+		})
+
+		var clauseErr *ClauseErr
+		if !errors.As(err, &clauseErr) || clauseErr.Clause != "bogus" {
+			t.Errorf("\nUnexpected error: %v", err)
+		}
+	}
+}
+
+func TestIDsQuery(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Value: []string{"1", "4", "100"},
+				Type:  IDs,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"ids":{"values":["1","4","100"]}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestBoostedOptionQueries(t *testing.T) {
+	body, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Or: []QueryItem{
+			{Value: Boosted([]string{"1"}, 2), Type: IDs},
+			{Field: "title", Value: Boosted("kimchy", 1.5), Type: MultiMatch},
+			{Field: "body", Value: Boosted(QueryStringItem{Query: "kimchy", Boost: 3}, 4), Type: QueryString},
+			{Value: Boosted(nil, 0.5), Type: MatchAll},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"should":[{"ids":{"boost":2,"values":["1"]}},{"multi_match":{"boost":1.5,"fields":["title"],"query":"kimchy"}},{"query_string":{"analyze_wildcard":true,"boost":4,"fields":["body"],"query":"kimchy"}},{"match_all":{"boost":0.5}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestCompoundQueryNames(t *testing.T) {
+	child := WrapQueryItems("and", QueryItem{Field: "tag", Value: "go", Type: Term})
+	tests := []struct {
+		item     QueryItem
+		expected string
+	}{
+		{
+			QueryItem{Type: Nested, Value: wrapQueryDoc("and", []QueryItem{{Field: "tag", Value: "go", Type: Term}}), Name: "tagged"},
+			`{"bool":{"must":[{"term":{"tag":"go"}}],"_name":"tagged"}}`,
+		},
+		{
+			QueryItem{Type: Nested, Value: WrapQueryItemsNamed("and", "inner", QueryItem{Field: "tag", Value: "go", Type: Term}).Value, Name: "tagged"},
+			`{"bool":{"must":[{"term":{"tag":"go"}}],"_name":"inner"}}`,
+		},
+		{
+			QueryItem{Type: MatchAll, Name: "all"},
+			`{"match_all":{"_name":"all"}}`,
+		},
+		{
+			QueryItem{Type: MatchNone, Name: "none"},
+			`{"match_none":{"_name":"none"}}`,
+		},
+		{
+			QueryItem{Field: "comments", Type: NestedQuery, Value: NestedQueryItem{And: []QueryItem{{Field: "comments.author", Value: "kimchy", Type: Term}}}, Name: "commented"},
+			`{"nested":{"_name":"commented","path":["comments"],"query":{"bool":{"must":[{"term":{"comments.author":"kimchy"}}]}}}}`,
+		},
+		{
+			QueryItem{Type: HasChild, Value: HasChildQueryItem{Type: "answer", Query: child}, Name: "answered"},
+			`{"has_child":{"_name":"answered","query":{"bool":{"must":[{"term":{"tag":"go"}}]}},"type":"answer"}}`,
+		},
+		{
+			QueryItem{Type: HasParent, Value: HasParentQueryItem{ParentType: "question", Query: child}, Name: "asked"},
+			`{"has_parent":{"_name":"asked","parent_type":"question","query":{"bool":{"must":[{"term":{"tag":"go"}}]}}}}`,
+		},
+		{
+			QueryItem{Type: FunctionScore, Value: FunctionScoreQueryItem{Query: child}, Name: "scored"},
+			`{"function_score":{"_name":"scored","query":{"bool":{"must":[{"term":{"tag":"go"}}]}}}}`,
+		},
+	}
+
+	for _, test := range tests {
+		body, err := json.Marshal(toLeafQuery(test.item))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		if string(body) != test.expected {
+			t.Errorf("\nWant: %q\nHave: %q", test.expected, string(body))
+		}
+	}
+}
+
+func TestIDsInFilter(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "index_*",
+		Filter: []QueryItem{
+			{
+				Value: []string{"1", "4"},
+				Type:  IDs,
+			},
+			IDsInIndex("index_2020", []string{"100"}),
+		},
+	})
+
+	expected := `{"query":{"bool":{"filter":[{"ids":{"values":["1","4"]}},{"bool":{"filter":[{"ids":{"values":["100"]}},{"term":{"_index":"index_2020"}}]}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestLargeIntegers(t *testing.T) {
+	const id = 9007199254740993
+
+	body, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Filter: []QueryItem{
+			{Field: "user_id", Value: int64(id), Type: Term},
+			{Field: "user_id", Value: Boosted(json.Number("9007199254740993"), 2), Type: Term},
+			{Field: "user_id", Value: []int64{id}, Type: Terms},
+			{Field: "user_id", Value: []json.Number{"9007199254740993"}, Type: Terms},
+			{Value: []json.Number{"9007199254740993"}, Type: IDs},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"filter":[{"term":{"user_id":9007199254740993}},{"term":{"user_id":{"boost":2,"value":9007199254740993}}},{"terms":{"user_id":[9007199254740993]}},{"terms":{"user_id":[9007199254740993]}},{"ids":{"values":[9007199254740993]}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestQueryTypeIsValid(t *testing.T) {
+	for _, qt := range []QueryType{Match, Terms, HasChild, Prefix} {
+		if !qt.IsValid() {
+			t.Errorf("\nWant %d to be valid", qt)
+		}
+	}
+
+	for _, qt := range []QueryType{-1, QueryType(len(queryTypeTokens)), 100001} {
+		if qt.IsValid() {
+			t.Errorf("\nWant %d to be invalid", qt)
+		}
+
+		var queryTypeErr *QueryTypeErr
+		if _, err := qt.String(); !errors.As(err, &queryTypeErr) {
+			t.Errorf("\nUnexpected error: %v", err)
+		}
+	}
+}
+
 func TestQueryStringEsc(t *testing.T) {
 	body, _ := json.Marshal(QueryDoc{
 		Index: "some_index",
@@ -37,164 +371,1372 @@ func TestQueryStringEsc(t *testing.T) {
 		},
 	})
 
-	expected := `{"query":{"bool":{"must":[{"query_string":{"analyze_wildcard":true,"fields":["user.id"],"query":"kimchy\\!"}}]}}}`
+	expected := `{"query":{"bool":{"must":[{"query_string":{"analyze_wildcard":true,"fields":["user.id"],"query":"kimchy\\!"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestQueryStringMultipleFields(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Value: QueryStringItem{
+					Query:  "kimchy",
+					Fields: []string{"title", "body"},
+				},
+				Type: QueryString,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"query_string":{"analyze_wildcard":true,"fields":["title","body"],"query":"kimchy"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestQueryStringDefaultField(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Value: QueryStringItem{
+					Query:        "kimchy",
+					DefaultField: "*",
+				},
+				Type: QueryString,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"query_string":{"analyze_wildcard":true,"default_field":"*","query":"kimchy"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestQueryStringOperatorAndLenient(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "title",
+				Value: QueryStringItem{
+					Query:           "quick brown",
+					DefaultOperator: "AND",
+					Lenient:         true,
+				},
+				Type: QueryString,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"query_string":{"analyze_wildcard":true,"default_operator":"AND","fields":["title"],"lenient":true,"query":"quick brown"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestQueryStringPhraseSlopAndLeadingWildcard(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "title",
+				Value: QueryStringItem{
+					Query:                "quick brown",
+					PhraseSlop:           2,
+					AllowLeadingWildcard: true,
+				},
+				Type: QueryString,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"query_string":{"allow_leading_wildcard":true,"analyze_wildcard":true,"fields":["title"],"phrase_slop":2,"query":"quick brown"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestQueryStringBoost(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "title",
+				Value: QueryStringItem{
+					Query: "kimchy",
+					Boost: 3,
+				},
+				Type: QueryString,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"query_string":{"analyze_wildcard":true,"boost":3,"fields":["title"],"query":"kimchy"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestWrapQueryItemsNamed(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			WrapQueryItemsNamed("or", "title_or_content",
+				QueryItem{
+					Field: "title",
+					Value: "Search",
+					Type:  Match,
+				},
+				QueryItem{
+					Field: "content",
+					Value: "Elasticsearch",
+					Type:  Match,
+				},
+			),
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"bool":{"should":[{"match":{"title":"Search"}},{"match":{"content":"Elasticsearch"}}],"_name":"title_or_content"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestWrapQueryItemsBoosted(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			WrapQueryItemsBoosted("or", 2,
+				QueryItem{
+					Field: "title",
+					Value: "Search",
+					Type:  Match,
+				},
+				QueryItem{
+					Field: "content",
+					Value: "Elasticsearch",
+					Type:  Match,
+				},
+			),
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"bool":{"should":[{"match":{"title":"Search"}},{"match":{"content":"Elasticsearch"}}],"boost":2}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestQueryStringAnalyzer(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "title",
+				Value: QueryStringItem{
+					Query:    "kimchy",
+					Analyzer: "simple",
+				},
+				Type: QueryString,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"query_string":{"analyze_wildcard":true,"analyzer":"simple","fields":["title"],"query":"kimchy"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestQueryStringQuoteFieldSuffix(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "title",
+				Value: QueryStringItem{
+					Query:            "kimchy",
+					QuoteFieldSuffix: ".exact",
+				},
+				Type: QueryString,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"query_string":{"analyze_wildcard":true,"fields":["title"],"query":"kimchy","quote_field_suffix":".exact"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestTopLevelBoost(t *testing.T) {
+	boost := 2.0
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "title",
+				Value: "Search",
+				Type:  Match,
+			},
+		},
+		Boost: &boost,
+	})
+
+	expected := `{"query":{"bool":{"must":[{"match":{"title":"Search"}}],"boost":2}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestTopLevelMinimumShouldMatch(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Filter: []QueryItem{
+			{Field: "status", Value: "published", Type: Term},
+		},
+		Or: []QueryItem{
+			{Field: "tags", Value: "featured", Type: Term},
+			{Field: "tags", Value: "pinned", Type: Term},
+		},
+		MinimumShouldMatch: 1,
+	})
+
+	expected := `{"query":{"bool":{"should":[{"term":{"tags":"featured"}},{"term":{"tags":"pinned"}}],"filter":[{"term":{"status":"published"}}],"minimum_should_match":1}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestQueryStringMinimumShouldMatch(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "title",
+				Value: QueryStringItem{
+					Query:              "quick brown fox",
+					MinimumShouldMatch: "75%",
+				},
+				Type: QueryString,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"query_string":{"analyze_wildcard":true,"fields":["title"],"minimum_should_match":"75%","query":"quick brown fox"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestMultiMatchMinimumShouldMatch(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Value: MultiMatchItem{
+					Query:              "quick brown fox",
+					Fields:             []string{"title", "body"},
+					MinimumShouldMatch: "75%",
+				},
+				Type: MultiMatch,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"multi_match":{"fields":["title","body"],"minimum_should_match":"75%","query":"quick brown fox"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+	query := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "title",
+				Value: "<b>Search</b> & \\u003c",
+				Type:  Match,
+			},
+		},
+		Sort: []map[string]string{{"id": "asc"}},
+	}
+
+	compact, _ := json.Marshal(query)
+	indented, err := query.MarshalIndent("", "  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{
+  "query": {
+    "bool": {
+      "must": [
+        {
+          "match": {
+            "title": "<b>Search</b> & \\u003c"
+          }
+        }
+      ]
+    }
+  },
+  "sort": [
+    {
+      "id": "asc"
+    }
+  ]
+}`
+	if string(indented) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(indented))
+	}
+
+	var compactObj, indentedObj interface{}
+	if err := json.Unmarshal(compact, &compactObj); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err := json.Unmarshal(indented, &indentedObj); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if !reflect.DeepEqual(compactObj, indentedObj) {
+		t.Errorf("\nWant: %v\nHave: %v", compactObj, indentedObj)
+	}
+}
+
+func TestMultiMatchBoost(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Or: []QueryItem{
+			{
+				Value: MultiMatchItem{
+					Query:  "quick brown fox",
+					Fields: []string{"title", "body"},
+					Boost:  2,
+				},
+				Type: MultiMatch,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"should":[{"multi_match":{"boost":2,"fields":["title","body"],"query":"quick brown fox"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestMultiMatchBoosted(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			MultiMatchBoosted("quick brown fox", map[string]float64{
+				"title": 3,
+				"body":  1,
+			}),
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"multi_match":{"fields":["title^3","body"],"query":"quick brown fox"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestMultiMatchFields(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And:   []QueryItem{MultiMatchFields("quick brown fox", []string{"title", "body"})},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"multi_match":{"fields":["title","body"],"query":"quick brown fox"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestMultiMatchPhrase(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And:   []QueryItem{MultiMatchPhrase("quick fox", []string{"title", "body"}, 2)},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"multi_match":{"fields":["title","body"],"query":"quick fox","slop":2,"type":"phrase"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestMultiSearchDoc(t *testing.T) {
+	doc, _ := MultiSearchDoc([]QueryDoc{
+		{
+			Index: "index1",
+			And: []QueryItem{
+				{
+					Field: "user.id",
+					Value: "kimchy!",
+					Type:  QueryString,
+				},
+			},
+		},
+		{
+			Index: "index2",
+			And: []QueryItem{
+				{
+					Field: "some_index_id",
+					Value: "some-long-key-id-value",
+					Type:  Match,
+				},
+			},
+		},
+	})
+
+	expected := `{"index":"index1"}
+{"query":{"bool":{"must":[{"query_string":{"analyze_wildcard":true,"fields":["user.id"],"query":"kimchy\\!"}}]}}}
+{"index":"index2"}
+{"query":{"bool":{"must":[{"match":{"some_index_id":"some-long-key-id-value"}}]}}}
+`
+	if string(doc) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(doc))
+	}
+}
+
+func TestMultiSearchDocEmptyIndex(t *testing.T) {
+	doc, _ := MultiSearchDoc([]QueryDoc{
+		{
+			And: []QueryItem{
+				{
+					Field: "some_index_id",
+					Value: "some-long-key-id-value",
+					Type:  Match,
+				},
+			},
+		},
+	})
+
+	expected := `{}
+{"query":{"bool":{"must":[{"match":{"some_index_id":"some-long-key-id-value"}}]}}}
+`
+	if string(doc) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(doc))
+	}
+}
+
+func TestMultiSearchDocIndexOptions(t *testing.T) {
+	allowNoIndices := false
+	doc, _ := MultiSearchDoc([]QueryDoc{
+		{
+			Index:             "logs-*",
+			IgnoreUnavailable: true,
+			And: []QueryItem{
+				{
+					Field: "level",
+					Value: "error",
+					Type:  Term,
+				},
+			},
+		},
+		{
+			Index:          "metrics-*",
+			AllowNoIndices: &allowNoIndices,
+		},
+	})
+
+	expected := `{"index":"logs-*","ignore_unavailable":true}
+{"query":{"bool":{"must":[{"term":{"level":"error"}}]}}}
+{"index":"metrics-*","allow_no_indices":false}
+{}
+`
+	if string(doc) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(doc))
+	}
+}
+
+func TestMultiSearchDocExpandWildcards(t *testing.T) {
+	doc, _ := MultiSearchDoc([]QueryDoc{
+		{
+			Index:           "logs-*",
+			ExpandWildcards: "open,hidden",
+		},
+	})
+
+	expected := `{"index":"logs-*","expand_wildcards":"open,hidden"}
+{}
+`
+	if string(doc) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(doc))
+	}
+}
+
+func TestMultiSearchDocSearchType(t *testing.T) {
+	doc, _ := MultiSearchDoc([]QueryDoc{
+		{
+			Index:      "some_index",
+			SearchType: "dfs_query_then_fetch",
+			And: []QueryItem{
+				{Field: "title", Value: "Search", Type: Match},
+			},
+		},
+	})
+
+	expected := `{"index":"some_index","search_type":"dfs_query_then_fetch"}
+{"query":{"bool":{"must":[{"match":{"title":"Search"}}]}}}
+`
+	if string(doc) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(doc))
+	}
+}
+
+func TestMarshalItems(t *testing.T) {
+	body, err := MarshalItems([]QueryItem{
+		{Field: "title", Value: "Search", Type: Match},
+		{Field: "status", Value: "published", Type: Term, Name: "published"},
+		AnyOf(
+			QueryItem{Field: "tags", Value: "featured", Type: Term},
+		),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `[{"match":{"title":"Search"}},{"term":{"status":{"_name":"published","value":"published"}}},{"bool":{"should":[{"term":{"tags":"featured"}}]}}]`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	body, _ = MarshalItems(nil)
+	if string(body) != "[]" {
+		t.Errorf("\nWant: %q\nHave: %q", "[]", string(body))
+	}
+
+	if _, err := MarshalItems([]QueryItem{{Value: "Search", Type: Match}}); err == nil {
+		t.Errorf("\nExpected an error for a match without a Field")
+	}
+}
+
+func TestMultiSearchDocIndices(t *testing.T) {
+	doc, _ := MultiSearchDoc([]QueryDoc{
+		{
+			Index:   "ignored",
+			Indices: []string{"index1", "index2"},
+		},
+		{
+			Index: "index3",
+		},
+	})
+
+	expected := `{"index":"index1,index2"}
+{}
+{"index":"index3"}
+{}
+`
+	if string(doc) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(doc))
+	}
+}
+
+func TestMultiSearchDocRouting(t *testing.T) {
+	doc, _ := MultiSearchDoc([]QueryDoc{
+		{
+			Index:   "some_index",
+			Routing: []string{"a", "b"},
+		},
+		{
+			Index:   "some_index",
+			Routing: []string{"c"},
+		},
+	})
+
+	expected := `{"index":"some_index","routing":"a,b"}
+{}
+{"index":"some_index","routing":"c"}
+{}
+`
+	if string(doc) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(doc))
+	}
+}
+
+func TestIndicesOf(t *testing.T) {
+	indices := IndicesOf([]QueryDoc{
+		{Index: "index2"},
+		{},
+		{Index: "index1"},
+		{Index: "index2"},
+		{Index: "ignored", Indices: []string{"index1", "index3"}},
+	})
+
+	expected := []string{"index2", "index1", "index3"}
+	if !reflect.DeepEqual(indices, expected) {
+		t.Errorf("\nWant: %q\nHave: %q", expected, indices)
+	}
+}
+
+func TestAndQuery(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Sort:  []map[string]string{{"id": "asc"}},
+		And: []QueryItem{
+			{
+				Field: "some_index_id",
+				Value: "some-long-key-id-value",
+				Type:  Match,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"match":{"some_index_id":"some-long-key-id-value"}}]}},"sort":[{"id":"asc"}]}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestSortByScore(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Sort:  []map[string]string{{"date": "desc"}, SortByScore("desc")},
+		And: []QueryItem{
+			{
+				Field: "title",
+				Value: "Search",
+				Type:  Match,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"match":{"title":"Search"}}]}},"sort":[{"date":"desc"},{"_score":"desc"}]}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestSortByDoc(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Sort:  []map[string]string{SortByDoc()},
+		Filter: []QueryItem{
+			{
+				Field: "status",
+				Value: "published",
+				Type:  Term,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"filter":[{"term":{"status":"published"}}]}},"sort":[{"_doc":"asc"}]}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestPage(t *testing.T) {
+	query := QueryDoc{Index: "some_index"}.Page(3, 20)
+	if query.From != 40 || query.Size != 20 {
+		t.Errorf("\nWant: from=40 size=20\nHave: from=%d size=%d", query.From, query.Size)
+	}
+
+	query = QueryDoc{Index: "some_index"}.Page(0, 20)
+	if query.From != 0 || query.Size != 20 {
+		t.Errorf("\nWant: from=0 size=20\nHave: from=%d size=%d", query.From, query.Size)
+	}
+}
+
+func TestSortByMixedClauses(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		SortBy: []interface{}{
+			map[string]string{"date": "desc"},
+			SortField{Field: "price", Order: "asc", Mode: "min"},
+			SortByScore("desc"),
+		},
+		Filter: []QueryItem{
+			{
+				Field: "status",
+				Value: "published",
+				Type:  Term,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"filter":[{"term":{"status":"published"}}]}},"sort":[{"date":"desc"},{"price":{"order":"asc","mode":"min"}},{"_score":"desc"}]}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestSourceFilter(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Source: SourceFilter{
+			Includes: []string{"a", "b.*"},
+			Excludes: []string{"c.secret"},
+		},
+	})
+
+	expected := `{"_source":{"includes":["a","b.*"],"excludes":["c.secret"]}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestSourcePattern(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index:  "some_index",
+		Source: "obj.*",
+	})
+
+	expected := `{"_source":"obj.*"}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestVersionSeqNoAndTerminateAfter(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Filter: []QueryItem{
+			{
+				Field: "status",
+				Value: "published",
+				Type:  Term,
+			},
+		},
+		Version:          true,
+		SeqNoPrimaryTerm: true,
+		TerminateAfter:   1000,
+	})
+
+	expected := `{"query":{"bool":{"filter":[{"term":{"status":"published"}}]}},"version":true,"seq_no_primary_term":true,"terminate_after":1000}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestWithSize(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{Index: "some_index"}.WithSize(0))
+
+	expected := `{"size":0}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	body, _ = json.Marshal(QueryDoc{Index: "some_index", Size: 0})
+
+	expected = `{}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	body, _ = json.Marshal(QueryDoc{Index: "some_index"}.WithSize(20).WithFrom(40))
+
+	expected = `{"size":20,"from":40}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestWithFrom(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{Index: "some_index"}.WithFrom(0))
+
+	expected := `{"from":0}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	body, _ = json.Marshal(QueryDoc{Index: "some_index", From: 0})
+
+	expected = `{}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestFields(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Fields: []interface{}{
+			"user.id",
+			FieldAndFormat{Field: "date", Format: "epoch_millis"},
+		},
+		Source: false,
+	})
+
+	expected := `{"_source":false,"fields":["user.id",{"field":"date","format":"epoch_millis"}]}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestNamedQueries(t *testing.T) {
+	body, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{Field: "title", Value: "Search", Type: Match, Name: "title_match"},
+			{Field: "body", Value: QueryStringItem{Query: "kimchy"}, Type: QueryString, Name: "body_qs"},
+		},
+		Filter: []QueryItem{
+			{Field: "status", Value: "published", Type: Term, Name: "published"},
+			{Field: "id", Value: []string{"1", "2"}, Type: Terms, Name: "ids"},
+			{Field: "publish_date", Value: map[string]string{"gte": "2015-01-01"}, Type: Range, Name: "recent"},
+		},
+		Or: []QueryItem{
+			WrapQueryItems("or",
+				QueryItem{Field: "tags", Value: "featured", Type: Term, Name: "featured"},
+			),
+		},
+		Profile: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"must":[{"match":{"title":{"_name":"title_match","query":"Search"}}},{"query_string":{"_name":"body_qs","analyze_wildcard":true,"fields":["body"],"query":"kimchy"}}],"should":[{"bool":{"should":[{"term":{"tags":{"_name":"featured","value":"featured"}}}]}}],"filter":[{"term":{"status":{"_name":"published","value":"published"}}},{"terms":{"_name":"ids","id":["1","2"]}},{"range":{"publish_date":{"_name":"recent","gte":"2015-01-01"}}}]}},"profile":true}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestTrackScores(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{Field: "title", Value: "Search", Type: Match},
+		},
+		Sort:        []map[string]string{{"date": "desc"}},
+		TrackScores: true,
+	})
+
+	expected := `{"query":{"bool":{"must":[{"match":{"title":"Search"}}]}},"sort":[{"date":"desc"}],"track_scores":true}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestBoosted(t *testing.T) {
+	body, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Or: []QueryItem{
+			{Field: "status", Value: Boosted("published", 2), Type: Term},
+			{Field: "user", Value: Boosted("KI*Y", 1.5), Type: Wildcard},
+			{Field: "title", Value: Boosted("elastic", 3), Type: Prefix},
+			{Field: "id", Value: Boosted([]string{"1", "2"}, 0.5), Type: Terms},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"should":[{"term":{"status":{"boost":2,"value":"published"}}},{"wildcard":{"user":{"boost":1.5,"value":"ki*y"}}},{"prefix":{"title":{"boost":3,"value":"elastic"}}},{"terms":{"boost":0.5,"id":["1","2"]}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestSetWildcardLowercase(t *testing.T) {
+	defer SetWildcardLowercase(true)
+	SetWildcardLowercase(false)
+
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{Field: "id", Value: "AbC-*", Type: Wildcard},
+			{Field: "user", Value: Boosted("KI*Y", 1.5), Type: Wildcard},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"wildcard":{"id":"AbC-*"}},{"wildcard":{"user":{"boost":1.5,"value":"KI*Y"}}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestExistsBoost(t *testing.T) {
+	body, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Or: []QueryItem{
+			{Field: "field", Value: Boosted("user", 1.5), Type: Exists},
+			{Field: "field", Value: "email", Type: Exists},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"should":[{"exists":{"boost":1.5,"field":"user"}},{"exists":{"field":"email"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestAggregationOnlyOmitsQuery(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Aggs: map[string]Agg{
+			"by_status": TermsAgg("status", 10),
+		},
+	}.WithSize(0))
+
+	expected := `{"size":0,"aggs":{"by_status":{"terms":{"field":"status","size":10}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestNotQuery(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Sort:  []map[string]string{{"id": "desc"}},
+		Not: []QueryItem{
+			{
+				Field: "some_index_id",
+				Value: "some-not-value",
+				Type:  Match,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must_not":[{"match":{"some_index_id":"some-not-value"}}]}},"sort":[{"id":"desc"}]}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestOrQuery(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Sort:  []map[string]string{{"id": "desc"}},
+		Or: []QueryItem{
+			{
+				Field: "some_index_id",
+				Value: "some-option-one",
+				Type:  Match,
+			},
+			{
+				Field: "some_index_id",
+				Value: "some-option-two",
+				Type:  Match,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"should":[{"match":{"some_index_id":"some-option-one"}},{"match":{"some_index_id":"some-option-two"}}]}},"sort":[{"id":"desc"}]}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestFilterQuery(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "title",
+				Value: "Search",
+				Type:  Match,
+			},
+			{
+				Field: "content",
+				Value: "Elasticsearch",
+				Type:  Match,
+			},
+		},
+		Filter: []QueryItem{
+			{
+				Field: "status",
+				Value: "published",
+				Type:  Term,
+			},
+			{
+				Field: "publish_date",
+				Value: map[string]string{
+					"gte": "2015-01-01",
+				},
+				Type: Range,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"match":{"title":"Search"}},{"match":{"content":"Elasticsearch"}}],"filter":[{"term":{"status":"published"}},{"range":{"publish_date":{"gte":"2015-01-01"}}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestBool(t *testing.T) {
+	must := []QueryItem{{Field: "title", Value: "Search", Type: Match}}
+	should := []QueryItem{{Field: "tags", Value: "featured", Type: Term}}
+	mustNot := []QueryItem{{Field: "status", Value: "deleted", Type: Term}}
+	filter := []QueryItem{{Field: "lang", Value: "en", Type: Term}}
+
+	body, _ := json.Marshal(Bool(must, should, mustNot, filter))
+	expected, _ := json.Marshal(QueryDoc{
+		And:    must,
+		Or:     should,
+		Not:    mustNot,
+		Filter: filter,
+	})
+	if string(body) != string(expected) {
+		t.Errorf("\nWant: %q\nHave: %q", string(expected), string(body))
+	}
+}
+
+func TestScored(t *testing.T) {
+	must := []QueryItem{{Field: "title", Value: "Search", Type: Match}}
+	filter := []QueryItem{{Field: "lang", Value: "en", Type: Term}}
+
+	body, _ := json.Marshal(Scored(must, filter))
+	expected, _ := json.Marshal(QueryDoc{
+		And:    must,
+		Filter: filter,
+	})
+	if string(body) != string(expected) {
+		t.Errorf("\nWant: %q\nHave: %q", string(expected), string(body))
+	}
+}
+
+func TestNestedQuery(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "nested_path",
+				Value: NestedQueryItem{
+					Filter: []QueryItem{WrapQueryItems("filter", QueryItem{
+						Field: "id",
+						Value: []string{"b4ab2c6e-93e3-40b9-8e66-9379f864186f"},
+						Type:  Terms,
+					})},
+				},
+				Type: NestedQuery,
+			},
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"nested":{"path":["nested_path"],"query":{"bool":{"filter":[{"bool":{"filter":[{"terms":{"id":["b4ab2c6e-93e3-40b9-8e66-9379f864186f"]}}]}}]}}}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestNot(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Filter: []QueryItem{
+			Not(QueryItem{
+				Field: "status",
+				Value: "deleted",
+				Type:  Term,
+			}),
+		},
+	})
+
+	expected := `{"query":{"bool":{"filter":[{"bool":{"must_not":[{"term":{"status":"deleted"}}]}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestAnyOf(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Filter: []QueryItem{
+			AnyOf(
+				QueryItem{Field: "status", Value: "draft", Type: Term},
+				QueryItem{Field: "status", Value: "published", Type: Term},
+			),
+		},
+	})
+
+	expected := `{"query":{"bool":{"filter":[{"bool":{"should":[{"term":{"status":"draft"}},{"term":{"status":"published"}}]}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestAllOf(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Or: []QueryItem{
+			AllOf(
+				QueryItem{Field: "title", Value: "Search", Type: Match},
+				QueryItem{Field: "status", Value: "published", Type: Term},
+			),
+		},
+	})
+
+	expected := `{"query":{"bool":{"should":[{"bool":{"must":[{"match":{"title":"Search"}},{"term":{"status":"published"}}]}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestAtLeast(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Filter: []QueryItem{
+			AtLeast(1,
+				QueryItem{Field: "serviceName", Value: "billing", Type: Match},
+				QueryItem{Field: "serviceName", Value: "payments", Type: Match},
+			),
+			AtLeast(1,
+				QueryItem{Field: "logKind", Value: "audit", Type: Match},
+				QueryItem{Field: "logKind", Value: "access", Type: Match},
+			),
+		},
+	})
+
+	expected := `{"query":{"bool":{"filter":[{"bool":{"should":[{"match":{"serviceName":"billing"}},{"match":{"serviceName":"payments"}}],"minimum_should_match":1}},{"bool":{"should":[{"match":{"logKind":"audit"}},{"match":{"logKind":"access"}}],"minimum_should_match":1}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestAllOfAnyOf(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			AllOfAnyOf(map[string][]interface{}{
+				"serviceName": {"billing", "payments"},
+				"logKind":     {"audit", "access"},
+			}),
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"bool":{"filter":[{"bool":{"should":[{"match":{"logKind":"audit"}},{"match":{"logKind":"access"}}],"minimum_should_match":1}},{"bool":{"should":[{"match":{"serviceName":"billing"}},{"match":{"serviceName":"payments"}}],"minimum_should_match":1}}]}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestAnyPhrase(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Filter: []QueryItem{
+			AnyPhrase("serviceName", "user service", "billing service"),
+			AnyPhrase("logKind", "error"),
+		},
+	})
+
+	expected := `{"query":{"bool":{"filter":[{"bool":{"should":[{"match_phrase":{"serviceName":"user service"}},{"match_phrase":{"serviceName":"billing service"}}],"minimum_should_match":1}},{"bool":{"should":[{"match_phrase":{"logKind":"error"}}],"minimum_should_match":1}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestPrefixOrWildcard(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			PrefixOrWildcard("sku", "ab12"),
+			PrefixOrWildcard("sku", ""),
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"bool":{"should":[{"prefix":{"sku":"ab12"}},{"wildcard":{"sku":"*ab12*"}}],"minimum_should_match":1}},{"match_none":{}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestPrefixOrWildcardLiteral(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			PrefixOrWildcard("sku", `AB*1?\2`),
+		},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"bool":{"should":[{"prefix":{"sku":"ab*1?\\2"}},{"wildcard":{"sku":"*ab\\*1\\?\\\\2*"}}],"minimum_should_match":1}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	defer SetWildcardLowercase(true)
+	SetWildcardLowercase(false)
+
+	body, _ = json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			PrefixOrWildcard("sku", "AB12"),
+		},
+	})
+
+	expected = `{"query":{"bool":{"must":[{"bool":{"should":[{"prefix":{"sku":"AB12"}},{"wildcard":{"sku":"*AB12*"}}],"minimum_should_match":1}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestSafeWildcard(t *testing.T) {
+	item, err := SafeWildcard("path", `c:\users\ki*`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	body, _ := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And:   []QueryItem{item},
+	})
+
+	expected := `{"query":{"bool":{"must":[{"wildcard":{"path":"c:\\\\users\\\\ki*"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	for _, pattern := range []string{"*chy", "?imchy"} {
+		_, err := SafeWildcard("user", pattern)
+		var leadingWildcardErr *LeadingWildcardErr
+		if !errors.As(err, &leadingWildcardErr) || leadingWildcardErr.Pattern != pattern {
+			t.Errorf("\nUnexpected error: %v", err)
+		}
+	}
+
+	body, _ = json.Marshal(QueryDoc{
+		Index: "some_index",
+		And:   []QueryItem{SafeWildcardAllowLeading("user", `*\chy`)},
+	})
+
+	expected = `{"query":{"bool":{"must":[{"wildcard":{"user":"*\\\\chy"}}]}}}`
 	if string(body) != expected {
 		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
 	}
 }
 
-func TestMultiSearchDoc(t *testing.T) {
-	doc, _ := MultiSearchDoc([]QueryDoc{
-		{
-			Index: "index1",
-			And: []QueryItem{
-				{
-					Field: "user.id",
-					Value: "kimchy!",
-					Type:  QueryString,
-				},
-			},
-		},
-		{
-			Index: "index2",
-			And: []QueryItem{
-				{
-					Field: "some_index_id",
-					Value: "some-long-key-id-value",
-					Type:  Match,
-				},
-			},
-		},
+func TestTermsOrMissing(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index:  "some_index",
+		Filter: []QueryItem{TermsOrMissing("region", []string{"us", "eu"})},
 	})
 
-	expected := `{"index":"index1"}
-{"query":{"bool":{"must":[{"query_string":{"analyze_wildcard":true,"fields":["user.id"],"query":"kimchy\\!"}}]}}}
-{"index":"index2"}
-{"query":{"bool":{"must":[{"match":{"some_index_id":"some-long-key-id-value"}}]}}}
-`
-	if string(doc) != expected {
-		t.Errorf("\nWant: %q\nHave: %q", expected, string(doc))
+	expected := `{"query":{"bool":{"filter":[{"bool":{"should":[{"terms":{"region":["us","eu"]}},{"bool":{"must_not":[{"exists":{"field":"region"}}]}}],"minimum_should_match":1}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
 	}
 }
 
-func TestAndQuery(t *testing.T) {
+func TestTermsOf(t *testing.T) {
 	body, _ := json.Marshal(QueryDoc{
 		Index: "some_index",
-		Sort:  []map[string]string{{"id": "asc"}},
-		And: []QueryItem{
-			{
-				Field: "some_index_id",
-				Value: "some-long-key-id-value",
-				Type:  Match,
-			},
+		Filter: []QueryItem{
+			TermsOf("region", []string{"us", "eu"}),
+			TermsOf("status", []int{200, 201}),
 		},
 	})
 
-	expected := `{"query":{"bool":{"must":[{"match":{"some_index_id":"some-long-key-id-value"}}]}},"sort":[{"id":"asc"}]}`
+	expected := `{"query":{"bool":{"filter":[{"terms":{"region":["us","eu"]}},{"terms":{"status":[200,201]}}]}}}`
 	if string(body) != expected {
 		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
 	}
 }
 
-func TestNotQuery(t *testing.T) {
+func TestFuzzyMatch(t *testing.T) {
 	body, _ := json.Marshal(QueryDoc{
 		Index: "some_index",
-		Sort:  []map[string]string{{"id": "desc"}},
-		Not: []QueryItem{
-			{
-				Field: "some_index_id",
-				Value: "some-not-value",
-				Type:  Match,
-			},
-		},
+		And:   []QueryItem{FuzzyMatch("title", "elasticsaerch", "AUTO")},
 	})
 
-	expected := `{"query":{"bool":{"must_not":[{"match":{"some_index_id":"some-not-value"}}]}},"sort":[{"id":"desc"}]}`
+	expected := `{"query":{"bool":{"must":[{"match":{"title":{"fuzziness":"AUTO","query":"elasticsaerch"}}}]}}}`
 	if string(body) != expected {
 		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
 	}
 }
 
-func TestOrQuery(t *testing.T) {
+func TestNestedInnerHitsSource(t *testing.T) {
 	body, _ := json.Marshal(QueryDoc{
 		Index: "some_index",
-		Sort:  []map[string]string{{"id": "desc"}},
-		Or: []QueryItem{
-			{
-				Field: "some_index_id",
-				Value: "some-option-one",
-				Type:  Match,
-			},
+		And: []QueryItem{
 			{
-				Field: "some_index_id",
-				Value: "some-option-two",
-				Type:  Match,
+				Field: "comments",
+				Value: NestedQueryItem{
+					And: []QueryItem{
+						{
+							Field: "comments.author",
+							Value: "kimchy",
+							Type:  Match,
+						},
+					},
+					InnerHits: &InnerHits{
+						Size:   3,
+						Source: SourceFilter{Excludes: []string{"comments.body"}},
+					},
+				},
+				Type: NestedQuery,
 			},
 		},
+		Source: true,
 	})
 
-	expected := `{"query":{"bool":{"should":[{"match":{"some_index_id":"some-option-one"}},{"match":{"some_index_id":"some-option-two"}}]}},"sort":[{"id":"desc"}]}`
+	expected := `{"query":{"bool":{"must":[{"nested":{"inner_hits":{"size":3,"_source":{"excludes":["comments.body"]}},"path":["comments"],"query":{"bool":{"must":[{"match":{"comments.author":"kimchy"}}]}}}}]}},"_source":true}`
 	if string(body) != expected {
 		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
 	}
 }
 
-func TestFilterQuery(t *testing.T) {
+func TestNestedQueryNameAndBoost(t *testing.T) {
 	body, _ := json.Marshal(QueryDoc{
 		Index: "some_index",
 		And: []QueryItem{
 			{
-				Field: "title",
-				Value: "Search",
-				Type:  Match,
-			},
-			{
-				Field: "content",
-				Value: "Elasticsearch",
-				Type:  Match,
-			},
-		},
-		Filter: []QueryItem{
-			{
-				Field: "status",
-				Value: "published",
-				Type:  Term,
-			},
-			{
-				Field: "publish_date",
-				Value: map[string]string{
-					"gte": "2015-01-01",
+				Field: "comments",
+				Value: NestedQueryItem{
+					And: []QueryItem{
+						{
+							Field: "comments.author",
+							Value: "kimchy",
+							Type:  Match,
+						},
+					},
+					Name:  "by_kimchy",
+					Boost: 2,
 				},
-				Type: Range,
+				Type: NestedQuery,
 			},
 		},
 	})
 
-	expected := `{"query":{"bool":{"must":[{"match":{"title":"Search"}},{"match":{"content":"Elasticsearch"}}],"filter":[{"term":{"status":"published"}},{"range":{"publish_date":{"gte":"2015-01-01"}}}]}}}`
+	expected := `{"query":{"bool":{"must":[{"nested":{"_name":"by_kimchy","boost":2,"path":["comments"],"query":{"bool":{"must":[{"match":{"comments.author":"kimchy"}}]}}}}]}}}`
 	if string(body) != expected {
 		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
 	}
 }
 
-func TestNestedQuery(t *testing.T) {
+func TestNestedQueryScoreMode(t *testing.T) {
 	body, _ := json.Marshal(QueryDoc{
 		Index: "some_index",
 		And: []QueryItem{
 			{
-				Field: "nested_path",
+				Field: "comments",
 				Value: NestedQueryItem{
-					Filter: []QueryItem{WrapQueryItems("filter", QueryItem{
-						Field: "id",
-						Value: []string{"b4ab2c6e-93e3-40b9-8e66-9379f864186f"},
-						Type:  Terms,
-					})},
+					And: []QueryItem{
+						{
+							Field: "comments.body",
+							Value: "elasticsearch",
+							Type:  Match,
+						},
+					},
+					ScoreMode: "sum",
 				},
 				Type: NestedQuery,
 			},
 		},
 	})
 
-	expected := `{"query":{"bool":{"must":[{"nested":{"path":["nested_path"],"query":{"bool":{"filter":[{"bool":{"filter":[{"terms":{"id":["b4ab2c6e-93e3-40b9-8e66-9379f864186f"]}}]}}]}}}}]}}}`
+	expected := `{"query":{"bool":{"must":[{"nested":{"path":["comments"],"query":{"bool":{"must":[{"match":{"comments.body":"elasticsearch"}}]}},"score_mode":"sum"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestAllExist(t *testing.T) {
+	body, _ := json.Marshal(QueryDoc{
+		Index:  "some_index",
+		Filter: []QueryItem{AllExist("user", "email", "created_at")},
+	})
+
+	expected := `{"query":{"bool":{"filter":[{"bool":{"filter":[{"exists":{"field":"user"}},{"exists":{"field":"email"}},{"exists":{"field":"created_at"}}]}}]}}}`
 	if string(body) != expected {
 		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
 	}
@@ -242,6 +1784,95 @@ func TestHasChildQuery(t *testing.T) {
 	}
 }
 
+func TestIgnoreUnmapped(t *testing.T) {
+	childQuery := WrapQueryItems("and", QueryItem{
+		Field: "Field1",
+		Value: "some-text",
+		Type:  Match,
+	})
+
+	tests := []struct {
+		item     QueryItem
+		expected string
+	}{
+		{
+			QueryItem{
+				Field: "comments",
+				Value: NestedQueryItem{
+					And:            []QueryItem{{Field: "comments.author", Value: "kimchy", Type: Match}},
+					IgnoreUnmapped: true,
+				},
+				Type: NestedQuery,
+			},
+			`{"query":{"bool":{"must":[{"nested":{"ignore_unmapped":true,"path":["comments"],"query":{"bool":{"must":[{"match":{"comments.author":"kimchy"}}]}}}}]}}}`,
+		},
+		{
+			QueryItem{
+				Value: HasChildQueryItem{
+					Query:          childQuery,
+					Type:           "childType",
+					IgnoreUnmapped: true,
+				},
+				Type: HasChild,
+			},
+			`{"query":{"bool":{"must":[{"has_child":{"ignore_unmapped":true,"query":{"bool":{"must":[{"match":{"Field1":"some-text"}}]}},"type":"childType"}}]}}}`,
+		},
+		{
+			QueryItem{
+				Value: HasParentQueryItem{
+					Query:          childQuery,
+					ParentType:     "parentType",
+					IgnoreUnmapped: true,
+				},
+				Type: HasParent,
+			},
+			`{"query":{"bool":{"must":[{"has_parent":{"ignore_unmapped":true,"parent_type":"parentType","query":{"bool":{"must":[{"match":{"Field1":"some-text"}}]}}}}]}}}`,
+		},
+	}
+
+	for _, test := range tests {
+		body, err := json.Marshal(QueryDoc{
+			Index: "some_index",
+			And:   []QueryItem{test.item},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+
+		if string(body) != test.expected {
+			t.Errorf("\nWant: %q\nHave: %q", test.expected, string(body))
+		}
+	}
+}
+
+func TestHasParentQuery(t *testing.T) {
+	body, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Value: HasParentQueryItem{
+					Query: WrapQueryItems("and", QueryItem{
+						Field: "tag",
+						Value: "Elasticsearch",
+						Type:  Term,
+					}),
+					ParentType: "parentType",
+				},
+				Type: HasParent,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"must":[{"has_parent":{"parent_type":"parentType","query":{"bool":{"must":[{"term":{"tag":"Elasticsearch"}}]}}}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
 func TestHasChildQueryInvalid(t *testing.T) {
 	_, err := json.Marshal(QueryDoc{
 		Index: "some_index",
@@ -262,3 +1893,232 @@ func TestHasChildQueryInvalid(t *testing.T) {
 		t.Errorf("\nUnexpected error: %v", err)
 	}
 }
+
+func TestJoinQueryInvalidInnerQuery(t *testing.T) {
+	items := []QueryItem{
+		{Value: HasChildQueryItem{Type: "child", Query: QueryItem{Field: "tag", Value: "go", Type: Term}}, Type: HasChild},
+		{Value: HasParentQueryItem{ParentType: "parent", Query: QueryItem{Field: "tag", Value: "go", Type: Term}}, Type: HasParent},
+	}
+
+	for _, item := range items {
+		_, err := json.Marshal(QueryDoc{
+			Index: "some_index",
+			And:   []QueryItem{item},
+		})
+
+		var valueTypeErr *ValueTypeErr
+		if !errors.As(err, &valueTypeErr) || valueTypeErr.Type != item.Type || valueTypeErr.Got != "string" {
+			t.Errorf("\nUnexpected error: %v", err)
+		}
+	}
+}
+
+func TestCompoundValueTypeMismatch(t *testing.T) {
+	items := []QueryItem{
+		{Field: "body", Value: 42, Type: QueryString},
+		{Value: 42, Type: MultiMatch},
+		{Value: "query", Type: FunctionScore},
+	}
+
+	for _, item := range items {
+		_, err := json.Marshal(QueryDoc{
+			Index: "some_index",
+			And:   []QueryItem{item},
+		})
+
+		var valueTypeErr *ValueTypeErr
+		if !errors.As(err, &valueTypeErr) || valueTypeErr.Type != item.Type {
+			t.Errorf("\nUnexpected error: %v", err)
+		}
+	}
+}
+
+func TestEqualJSON(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{`{"query":{"bool":{"must":[]}},"size":10}`, `{"size":10,"query":{"bool":{"must":[]}}}`, true},
+		{`{"a":1,"b":{"c":true,"d":null}}`, "{\n  \"b\": {\"d\": null, \"c\": true},\n  \"a\": 1.0\n}", true},
+		{`{"sort":["a","b"]}`, `{"sort":["b","a"]}`, false},
+		{`{"size":10}`, `{"size":"10"}`, false},
+		{`{"size":10}`, `{"size":10`, false},
+		{`{"size":10}`, `{"size":10}{}`, false},
+		{`{"id":9007199254740993}`, `{"id":9007199254740992}`, false},
+		{`{"id":9007199254740993}`, `{"id":9.007199254740993e15}`, true},
+	}
+
+	for _, test := range tests {
+		if equal := EqualJSON([]byte(test.a), []byte(test.b)); equal != test.equal {
+			t.Errorf("\nWant: %v\nHave: %v\nFor: %s and %s", test.equal, equal, test.a, test.b)
+		}
+	}
+}
+
+func TestMarshalDeterministic(t *testing.T) {
+	query := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Field: "body",
+				Value: QueryStringItem{
+					Query:           "kimchy",
+					Fields:          []string{"body", "title"},
+					DefaultOperator: "AND",
+					Lenient:         true,
+					Analyzer:        "standard",
+				},
+				Type: QueryString,
+				Name: "body_qs",
+			},
+			{Field: "title", Value: map[string]interface{}{"query": "Search", "operator": "and", "fuzziness": "AUTO"}, Type: Match, Name: "title"},
+		},
+		Filter: []QueryItem{
+			{Field: "publish_date", Value: map[string]string{"gte": "2015-01-01", "lte": "2016-01-01", "format": "yyyy-MM-dd"}, Type: Range, Name: "recent"},
+			{Field: "status", Value: Boosted("published", 2), Type: Term, Name: "published"},
+		},
+	}
+
+	first, err := json.Marshal(query)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	for i := 0; i < 100; i++ {
+		body, _ := json.Marshal(query)
+		if string(body) != string(first) {
+			t.Fatalf("\nWant: %q\nHave: %q", string(first), string(body))
+		}
+	}
+}
+
+func TestScopeTo(t *testing.T) {
+	query := QueryDoc{
+		Index: "some_index",
+		Filter: []QueryItem{
+			{Field: "status", Value: "published", Type: Term},
+		},
+	}
+
+	body, _ := json.Marshal(query.ScopeTo("tenant_id", 42))
+	expected := `{"query":{"bool":{"filter":[{"term":{"status":"published"}},{"term":{"tenant_id":42}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	body, _ = json.Marshal(QueryDoc{Index: "some_index"}.ScopeTo("tenant_id", "acme"))
+	expected = `{"query":{"bool":{"filter":[{"term":{"tenant_id":"acme"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	if len(query.Filter) != 1 {
+		t.Errorf("\nWant: %d\nHave: %d", 1, len(query.Filter))
+	}
+}
+
+func TestAddClauses(t *testing.T) {
+	title := QueryItem{Field: "title", Value: "Search", Type: Match}
+	status := QueryItem{Field: "status", Value: "published", Type: Term}
+	tags := QueryItem{Field: "tags", Value: "featured", Type: Term}
+	hidden := QueryItem{Field: "hidden", Value: true, Type: Term}
+
+	query := QueryDoc{Index: "some_index", And: []QueryItem{title}}
+	tests := []struct {
+		name     string
+		query    QueryDoc
+		expected string
+	}{
+		{"must", query.AddMust(tags), `{"query":{"bool":{"must":[{"match":{"title":"Search"}},{"term":{"tags":"featured"}}]}}}`},
+		{"should", query.AddShould(tags), `{"query":{"bool":{"must":[{"match":{"title":"Search"}}],"should":[{"term":{"tags":"featured"}}]}}}`},
+		{"must_not", query.AddMustNot(hidden), `{"query":{"bool":{"must":[{"match":{"title":"Search"}}],"must_not":[{"term":{"hidden":true}}]}}}`},
+		{"filter", query.AddFilter(status, tags), `{"query":{"bool":{"must":[{"match":{"title":"Search"}}],"filter":[{"term":{"status":"published"}},{"term":{"tags":"featured"}}]}}}`},
+		{"chained", query.AddFilter(status).AddMustNot(hidden), `{"query":{"bool":{"must":[{"match":{"title":"Search"}}],"must_not":[{"term":{"hidden":true}}],"filter":[{"term":{"status":"published"}}]}}}`},
+	}
+
+	for _, test := range tests {
+		body, _ := json.Marshal(test.query)
+		if string(body) != test.expected {
+			t.Errorf("%s\nWant: %q\nHave: %q", test.name, test.expected, string(body))
+		}
+	}
+
+	if len(query.And) != 1 || query.Filter != nil {
+		t.Errorf("\nUnexpected changes to the original query: %+v", query)
+	}
+}
+
+func TestReset(t *testing.T) {
+	query := QueryDoc{
+		Index: "some_index",
+		Size:  10,
+		And: []QueryItem{
+			{Field: "title", Value: "Search", Type: Match},
+		},
+		Filter: []QueryItem{
+			{Field: "status", Value: "published", Type: Term},
+		},
+	}.WithSize(0)
+	capacity := cap(query.And)
+
+	query.Reset()
+	query.Filter = append(query.Filter, QueryItem{Field: "user", Value: "kimchy", Type: Term})
+
+	body, _ := json.Marshal(query)
+	expected := `{"query":{"bool":{"filter":[{"term":{"user":"kimchy"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+	if len(query.And) != 0 || cap(query.And) != capacity {
+		t.Errorf("\nWant: len 0, cap %d\nHave: len %d, cap %d", capacity, len(query.And), cap(query.And))
+	}
+}
+
+func TestResetClone(t *testing.T) {
+	items := []QueryItem{
+		{Field: "title", Value: "Search", Type: Match},
+	}
+	base := QueryDoc{Index: "some_index", And: items}
+
+	query := base.WithSize(10).Clone()
+	query.Reset()
+	query.And = append(query.And, QueryItem{Field: "user", Value: "kimchy", Type: Term})
+
+	if items[0].Field != "title" {
+		t.Errorf("\nWant: %q\nHave: %q", "title", items[0].Field)
+	}
+
+	body, err := json.Marshal(base)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"must":[{"match":{"title":"Search"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func buildBenchmarkQuery(query *QueryDoc) {
+	query.Index = "some_index"
+	for i := 0; i < 8; i++ {
+		query.And = append(query.And, QueryItem{Field: "title", Value: "Search", Type: Match})
+		query.Filter = append(query.Filter, QueryItem{Field: "status", Value: "published", Type: Term})
+	}
+}
+
+func BenchmarkQueryDocFresh(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		query := &QueryDoc{}
+		buildBenchmarkQuery(query)
+	}
+}
+
+func BenchmarkQueryDocReset(b *testing.B) {
+	b.ReportAllocs()
+	query := &QueryDoc{}
+	for i := 0; i < b.N; i++ {
+		query.Reset()
+		buildBenchmarkQuery(query)
+	}
+}
@@ -0,0 +1,55 @@
+package esquerydsl
+
+// Query is a node in a boolean expression tree of QueryItems, built with
+// the Leaf, And, Or and NotAny combinators. Compile renders the tree into
+// a QueryDoc, nesting sub expressions via WrapQueryItems
+type Query struct {
+	clause string
+	leaf   QueryItem
+	nodes  []Query
+}
+
+// Leaf builds an expression out of a single QueryItem
+func Leaf(item QueryItem) Query {
+	return Query{leaf: item}
+}
+
+// And builds an expression matching documents that match every node
+func And(nodes ...Query) Query {
+	return Query{clause: "and", nodes: nodes}
+}
+
+// Or builds an expression matching documents that match any node
+func Or(nodes ...Query) Query {
+	return Query{clause: "or", nodes: nodes}
+}
+
+// NotAny builds an expression matching documents that match none of the nodes
+func NotAny(nodes ...Query) Query {
+	return Query{clause: "not", nodes: nodes}
+}
+
+// Compile renders the expression into a QueryDoc
+func (q Query) Compile() QueryDoc {
+	if q.clause == "" {
+		return QueryDoc{And: []QueryItem{q.leaf}}
+	}
+
+	return wrapQueryDoc(q.clause, compileNodes(q.nodes))
+}
+
+func (q Query) queryItem() QueryItem {
+	if q.clause == "" {
+		return q.leaf
+	}
+
+	return WrapQueryItems(q.clause, compileNodes(q.nodes)...)
+}
+
+func compileNodes(nodes []Query) []QueryItem {
+	items := make([]QueryItem, 0, len(nodes))
+	for _, node := range nodes {
+		items = append(items, node.queryItem())
+	}
+	return items
+}
@@ -0,0 +1,45 @@
+package esquerydsl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	expr := And(
+		Leaf(QueryItem{Field: "title", Value: "Search", Type: Match}),
+		Or(
+			Leaf(QueryItem{Field: "status", Value: "draft", Type: Term}),
+			Leaf(QueryItem{Field: "status", Value: "published", Type: Term}),
+		),
+		NotAny(
+			Leaf(QueryItem{Field: "tags", Value: "spam", Type: Term}),
+		),
+	)
+
+	body, _ := json.Marshal(expr.Compile())
+	expected, _ := json.Marshal(QueryDoc{
+		And: []QueryItem{
+			{Field: "title", Value: "Search", Type: Match},
+			WrapQueryItems("or",
+				QueryItem{Field: "status", Value: "draft", Type: Term},
+				QueryItem{Field: "status", Value: "published", Type: Term},
+			),
+			WrapQueryItems("not",
+				QueryItem{Field: "tags", Value: "spam", Type: Term},
+			),
+		},
+	})
+	if string(body) != string(expected) {
+		t.Errorf("\nWant: %q\nHave: %q", string(expected), string(body))
+	}
+}
+
+func TestCompileLeaf(t *testing.T) {
+	body, _ := json.Marshal(Leaf(QueryItem{Field: "title", Value: "Search", Type: Match}).Compile())
+
+	expected := `{"query":{"bool":{"must":[{"match":{"title":"Search"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
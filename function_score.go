@@ -0,0 +1,189 @@
+package esquerydsl
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// BoostMode controls how a function_score query combines the
+// score of its query with the score of its functions
+type BoostMode string
+
+// These are the boost modes supported by function_score
+const (
+	BoostModeMultiply BoostMode = "multiply"
+	BoostModeReplace  BoostMode = "replace"
+	BoostModeSum      BoostMode = "sum"
+	BoostModeAvg      BoostMode = "avg"
+	BoostModeMax      BoostMode = "max"
+	BoostModeMin      BoostMode = "min"
+)
+
+// ScoreMode controls how a function_score query combines
+// the scores of its functions with one another
+type ScoreMode string
+
+// These are the score modes supported by function_score
+const (
+	ScoreModeMultiply ScoreMode = "multiply"
+	ScoreModeSum      ScoreMode = "sum"
+	ScoreModeAvg      ScoreMode = "avg"
+	ScoreModeFirst    ScoreMode = "first"
+	ScoreModeMax      ScoreMode = "max"
+	ScoreModeMin      ScoreMode = "min"
+)
+
+// FunctionScoreQueryItem is used to construct a function_score query.
+// The Query attr is the query whose hits get rescored, matching every
+// document when left empty, and the Functions attr lists the functions
+// computing the new scores. The remaining attrs are only emitted when set
+type FunctionScoreQueryItem struct {
+	Query     QueryItem
+	Functions []ScoreFunction
+	BoostMode BoostMode
+	ScoreMode ScoreMode
+	MaxBoost  float64
+	MinScore  float64
+}
+
+// ScoreFunction is a single function of a function_score query. The Type
+// attr is the function kind (eg: "field_value_factor") and the Body attr is
+// what gets emitted under that kind; leave both empty for a weight only
+// function. Filter, when set, restricts the function to matching documents
+type ScoreFunction struct {
+	Type   string
+	Body   interface{}
+	Filter *QueryItem
+	Weight float64
+}
+
+// MarshalJSON will convert the ScoreFunction struct into its ES representation
+func (f ScoreFunction) MarshalJSON() ([]byte, error) {
+	body := make(map[string]interface{})
+	if f.Type != "" {
+		body[f.Type] = f.Body
+	}
+	if f.Filter != nil {
+		body["filter"] = toLeafQuery(*f.Filter)
+	}
+	if f.Weight != 0 {
+		body["weight"] = f.Weight
+	}
+
+	return json.Marshal(body)
+}
+
+func (q leafQuery) handleFunctionScore() ([]byte, error) {
+	item, ok := q.Value.(FunctionScoreQueryItem)
+	if !ok {
+		return nil, &ValueTypeErr{Type: FunctionScore, Got: fmt.Sprintf("%T", q.Value)}
+	}
+
+	body := make(map[string]interface{})
+	if len(item.Functions) > 0 {
+		body["functions"] = item.Functions
+	}
+
+	if item.Query.Value != nil {
+		body["query"] = toLeafQuery(item.Query)
+	}
+
+	if item.BoostMode != "" {
+		body["boost_mode"] = item.BoostMode
+	}
+
+	if item.ScoreMode != "" {
+		body["score_mode"] = item.ScoreMode
+	}
+
+	if item.MaxBoost != 0 {
+		body["max_boost"] = item.MaxBoost
+	}
+
+	if item.MinScore != 0 {
+		body["min_score"] = item.MinScore
+	}
+
+	if q.QueryName != "" {
+		body["_name"] = q.QueryName
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"function_score": body,
+	})
+}
+
+// GaussDecay builds a gauss decay function scoring documents by how far
+// the value of field is from origin. The offset (if non nil) and decay (if
+// non zero) attrs are only emitted when set
+func GaussDecay(field string, origin, scale, offset interface{}, decay float64) ScoreFunction {
+	return decayFunction("gauss", field, origin, scale, offset, decay)
+}
+
+// LinearDecay is like GaussDecay, but builds a linear decay function
+func LinearDecay(field string, origin, scale, offset interface{}, decay float64) ScoreFunction {
+	return decayFunction("linear", field, origin, scale, offset, decay)
+}
+
+// ExpDecay is like GaussDecay, but builds an exp decay function
+func ExpDecay(field string, origin, scale, offset interface{}, decay float64) ScoreFunction {
+	return decayFunction("exp", field, origin, scale, offset, decay)
+}
+
+func decayFunction(kind, field string, origin, scale, offset interface{}, decay float64) ScoreFunction {
+	params := map[string]interface{}{
+		"origin": origin,
+		"scale":  scale,
+	}
+	if offset != nil {
+		params["offset"] = offset
+	}
+	if decay != 0 {
+		params["decay"] = decay
+	}
+
+	return ScoreFunction{
+		Type: kind,
+		Body: map[string]interface{}{field: params},
+	}
+}
+
+// FieldValueFactor builds a field_value_factor function scoring documents
+// by the value of field. The factor, modifier (eg: "log1p") and missing
+// attrs are only emitted when set
+func FieldValueFactor(field string, factor float64, modifier string, missing interface{}) ScoreFunction {
+	params := map[string]interface{}{
+		"field": field,
+	}
+	if factor != 0 {
+		params["factor"] = factor
+	}
+	if modifier != "" {
+		params["modifier"] = modifier
+	}
+	if missing != nil {
+		params["missing"] = missing
+	}
+
+	return ScoreFunction{
+		Type: "field_value_factor",
+		Body: params,
+	}
+}
+
+// RandomScore builds a random_score function. Scores are stable for a
+// given seed, which is read from field (eg: "_seq_no") of each document;
+// field is omitted when empty
+func RandomScore(seed interface{}, field string) ScoreFunction {
+	params := map[string]interface{}{
+		"seed": seed,
+	}
+	if field != "" {
+		params["field"] = field
+	}
+
+	return ScoreFunction{
+		Type: "random_score",
+		Body: params,
+	}
+}
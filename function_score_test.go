@@ -0,0 +1,132 @@
+package esquerydsl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFunctionScoreModes(t *testing.T) {
+	body, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Value: FunctionScoreQueryItem{
+					Query: QueryItem{
+						Field: "title",
+						Value: "Search",
+						Type:  Match,
+					},
+					Functions: []ScoreFunction{
+						{
+							Filter: &QueryItem{Field: "featured", Value: true, Type: Term},
+							Weight: 2,
+						},
+					},
+					BoostMode: BoostModeMultiply,
+					ScoreMode: ScoreModeSum,
+					MaxBoost:  10,
+				},
+				Type: FunctionScore,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"must":[{"function_score":{"boost_mode":"multiply","functions":[{"filter":{"term":{"featured":true}},"weight":2}],"max_boost":10,"query":{"match":{"title":"Search"}},"score_mode":"sum"}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestFunctionScoreWithoutFunctions(t *testing.T) {
+	body, err := json.Marshal(toLeafQuery(QueryItem{
+		Value: FunctionScoreQueryItem{
+			Query:    QueryItem{Field: "title", Value: "Search", Type: Match},
+			MinScore: 2,
+		},
+		Type: FunctionScore,
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"function_score":{"min_score":2,"query":{"match":{"title":"Search"}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestDecayFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       ScoreFunction
+		expected string
+	}{
+		{
+			name:     "geo gauss",
+			fn:       GaussDecay("location", GeoPoint{Lat: 40.71, Lon: -74.01}, "2km", "500m", 0.5),
+			expected: `{"gauss":{"location":{"decay":0.5,"offset":"500m","origin":{"lat":40.71,"lon":-74.01},"scale":"2km"}}}`,
+		},
+		{
+			name:     "date gauss",
+			fn:       GaussDecay("published", "now", "10d", nil, 0),
+			expected: `{"gauss":{"published":{"origin":"now","scale":"10d"}}}`,
+		},
+		{
+			name:     "linear",
+			fn:       LinearDecay("price", 20, 10, nil, 0),
+			expected: `{"linear":{"price":{"origin":20,"scale":10}}}`,
+		},
+		{
+			name:     "exp",
+			fn:       ExpDecay("age", 0, 5, 1, 0.3),
+			expected: `{"exp":{"age":{"decay":0.3,"offset":1,"origin":0,"scale":5}}}`,
+		},
+	}
+
+	for _, test := range tests {
+		body, err := json.Marshal(test.fn)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err.Error())
+		}
+		if string(body) != test.expected {
+			t.Errorf("%s\nWant: %q\nHave: %q", test.name, test.expected, string(body))
+		}
+	}
+}
+
+func TestFieldValueFactor(t *testing.T) {
+	body, err := json.Marshal(FieldValueFactor("likes", 1.2, "log1p", 1))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"field_value_factor":{"factor":1.2,"field":"likes","missing":1,"modifier":"log1p"}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	body, err = json.Marshal(FieldValueFactor("likes", 0, "", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected = `{"field_value_factor":{"field":"likes"}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestRandomScore(t *testing.T) {
+	body, err := json.Marshal(RandomScore(123, "_seq_no"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"random_score":{"field":"_seq_no","seed":123}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
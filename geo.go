@@ -0,0 +1,84 @@
+package esquerydsl
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GeoPoint is the point representation shared by the geo queries.
+// It marshals into the {"lat": ..., "lon": ...} object form
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// GeoPointErr is a custom err returned if we are unable to parse
+// a GeoPoint out of the given input
+type GeoPointErr struct {
+	input string
+}
+
+func (e *GeoPointErr) Error() string {
+	return fmt.Sprintf("%q is not a valid geo point", e.input)
+}
+
+// ParseGeoPoint builds a GeoPoint out of a "lat,lon" string
+func ParseGeoPoint(latLon string) (GeoPoint, error) {
+	parts := strings.Split(latLon, ",")
+	if len(parts) != 2 {
+		return GeoPoint{}, &GeoPointErr{input: latLon}
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return GeoPoint{}, &GeoPointErr{input: latLon}
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || math.IsNaN(lon) || lon < -180 || lon > 180 {
+		return GeoPoint{}, &GeoPointErr{input: latLon}
+	}
+
+	return GeoPoint{Lat: lat, Lon: lon}, nil
+}
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// GeoPointFromGeohash builds a GeoPoint out of a geohash, using
+// the center of the cell that the geohash describes
+func GeoPointFromGeohash(geohash string) (GeoPoint, error) {
+	if geohash == "" {
+		return GeoPoint{}, &GeoPointErr{input: geohash}
+	}
+
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	isLon := true
+	for _, char := range strings.ToLower(geohash) {
+		idx := strings.IndexRune(geohashAlphabet, char)
+		if idx < 0 {
+			return GeoPoint{}, &GeoPointErr{input: geohash}
+		}
+
+		// each character encodes 5 bits, alternating between lon and lat
+		for bit := 4; bit >= 0; bit-- {
+			bounds := &latRange
+			if isLon {
+				bounds = &lonRange
+			}
+			mid := (bounds[0] + bounds[1]) / 2
+			if idx&(1<<uint(bit)) != 0 {
+				bounds[0] = mid
+			} else {
+				bounds[1] = mid
+			}
+			isLon = !isLon
+		}
+	}
+
+	return GeoPoint{
+		Lat: (latRange[0] + latRange[1]) / 2,
+		Lon: (lonRange[0] + lonRange[1]) / 2,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package esquerydsl
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseGeoPoint(t *testing.T) {
+	point, err := ParseGeoPoint("40.7128, -74.006")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	body, _ := json.Marshal(point)
+	expected := `{"lat":40.7128,"lon":-74.006}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestParseGeoPointInvalid(t *testing.T) {
+	for _, input := range []string{"", "40.7128", "a,b", "91,0", "0,181", "NaN,0", "0,NaN"} {
+		_, err := ParseGeoPoint(input)
+
+		var geoPointErr *GeoPointErr
+		if !errors.As(err, &geoPointErr) {
+			t.Errorf("\nUnexpected error for %q: %v", input, err)
+		}
+	}
+}
+
+func TestGeoPointFromGeohash(t *testing.T) {
+	point, err := GeoPointFromGeohash("dr5regw3pg6")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if math.Abs(point.Lat-40.7128) > 0.0001 || math.Abs(point.Lon+74.006) > 0.0001 {
+		t.Errorf("\nWant: %v\nHave: %v", GeoPoint{Lat: 40.7128, Lon: -74.006}, point)
+	}
+}
+
+func TestGeoPointFromGeohashInvalid(t *testing.T) {
+	for _, input := range []string{"", "dr5a"} {
+		_, err := GeoPointFromGeohash(input)
+
+		var geoPointErr *GeoPointErr
+		if !errors.As(err, &geoPointErr) {
+			t.Errorf("\nUnexpected error for %q: %v", input, err)
+		}
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/mottaquikarim/esquerydsl
 
-go 1.15
\ No newline at end of file
+go 1.18
\ No newline at end of file
@@ -0,0 +1,99 @@
+package esquerydsl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// LintMaxTerms is the number of values past which Lint flags a terms
+// query, as huge lists are slow to parse and to run
+var LintMaxTerms = 1000
+
+// LintMaxFrom is the from past which Lint flags the QueryDoc's pagination,
+// as ES has to collect every skipped hit on every shard
+var LintMaxFrom = 1000
+
+// Warning is an anti-pattern found by Lint. Path locates the offending
+// clause, eg: "filter[0].bool.should[1]"
+type Warning struct {
+	Message string
+	Path    string
+}
+
+// Lint checks the QueryDoc for anti-patterns that ES accepts but that
+// are usually slow or a mistake. Unlike Validate, none of these make the
+// query fail
+func Lint(doc QueryDoc) []Warning {
+	var warnings []Warning
+	if doc.From > LintMaxFrom {
+		warnings = append(warnings, Warning{
+			Message: fmt.Sprintf("from of %d is deep pagination, use search_after instead", doc.From),
+			Path:    "from",
+		})
+	}
+
+	doc.visit(func(item QueryItem, path string, inFilter bool) {
+		warnings = append(warnings, lintItem(item, path, inFilter)...)
+	})
+	return warnings
+}
+
+// lintItem checks a single QueryItem, compound queries being visited on
+// their own
+func lintItem(item QueryItem, path string, inFilter bool) []Warning {
+	value := item.Value
+	if boosted, ok := value.(BoostValue); ok {
+		value = boosted.Value
+	}
+
+	var warnings []Warning
+	if inFilter && scoringTypes[item.Type] {
+		warnings = append(warnings, Warning{
+			Message: scoringInFilterProblem(item),
+			Path:    path,
+		})
+	}
+
+	switch item.Type {
+	case Wildcard:
+		if pattern, ok := value.(string); ok && strings.IndexAny(pattern, "*?") == 0 {
+			warnings = append(warnings, Warning{
+				Message: fmt.Sprintf("wildcard query on %q starts with a wildcard and has to scan every term", item.Field),
+				Path:    path,
+			})
+		}
+	case Regexp:
+		if pattern, ok := value.(string); ok && (strings.HasPrefix(pattern, ".*") || strings.HasPrefix(pattern, ".+")) {
+			warnings = append(warnings, Warning{
+				Message: fmt.Sprintf("regexp query on %q is not anchored to a prefix and has to scan every term", item.Field),
+				Path:    path,
+			})
+		}
+	case Terms:
+		if list := reflect.ValueOf(value); list.Kind() == reflect.Slice && list.Len() > LintMaxTerms {
+			warnings = append(warnings, Warning{
+				Message: fmt.Sprintf("terms query on %q has %d values, more than %d", item.Field, list.Len(), LintMaxTerms),
+				Path:    path,
+			})
+		}
+	}
+
+	return warnings
+}
+
+// scoringTypes are the query types whose only advantage over their
+// term level counterparts is scoring, which is wasted in filter context
+var scoringTypes = map[QueryType]bool{
+	Match:       true,
+	MatchPhrase: true,
+}
+
+// scoringInFilterProblem describes a scoring query found in filter context
+func scoringInFilterProblem(item QueryItem) string {
+	queryType, _ := item.Type.String()
+	return fmt.Sprintf(
+		"%s query on %q is in filter context where it is not scored, a term query is usually intended",
+		queryType, item.Field,
+	)
+}
@@ -0,0 +1,74 @@
+package esquerydsl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	ids := make([]int, 1001)
+	doc := QueryDoc{
+		Index: "some_index",
+		From:  5000,
+		And: []QueryItem{
+			{Field: "user", Value: "*chy", Type: Wildcard},
+			{Field: "user", Value: "kim*", Type: Wildcard},
+			{
+				Value: HasChildQueryItem{
+					Type:  "comment",
+					Query: QueryItem{Field: "author", Value: "?imchy", Type: Wildcard},
+				},
+				Type: HasChild,
+			},
+		},
+		Filter: []QueryItem{
+			{Field: "id", Value: ids, Type: Terms},
+			AnyOf(
+				QueryItem{Field: "title", Value: "elasticsearch", Type: Match},
+				QueryItem{Field: "path", Value: Boosted(".*/logs", 2), Type: Regexp},
+				QueryItem{Field: "path", Value: "/var/.*", Type: Regexp},
+			),
+		},
+	}
+
+	expected := []Warning{
+		{Message: "from of 5000 is deep pagination, use search_after instead", Path: "from"},
+		{Message: `wildcard query on "user" starts with a wildcard and has to scan every term`, Path: "must[0]"},
+		{Message: `wildcard query on "author" starts with a wildcard and has to scan every term`, Path: "must[2].has_child.query"},
+		{Message: `terms query on "id" has 1001 values, more than 1000`, Path: "filter[0]"},
+		{Message: `match query on "title" is in filter context where it is not scored, a term query is usually intended`, Path: "filter[1].bool.should[0]"},
+		{Message: `regexp query on "path" is not anchored to a prefix and has to scan every term`, Path: "filter[1].bool.should[1]"},
+	}
+	if warnings := Lint(doc); !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("\nWant: %v\nHave: %v", expected, warnings)
+	}
+}
+
+func TestLintClean(t *testing.T) {
+	doc := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{Field: "title", Value: "elasticsearch", Type: Match},
+		},
+		Filter: []QueryItem{
+			{Field: "status", Value: "published", Type: Term},
+		},
+	}
+
+	if warnings := Lint(doc); len(warnings) != 0 {
+		t.Errorf("\nUnexpected warnings: %v", warnings)
+	}
+}
+
+func TestRegexpQuery(t *testing.T) {
+	body, err := json.Marshal(toLeafQuery(QueryItem{Field: "path", Value: Boosted("/var/.*", 2), Type: Regexp, Name: "logs"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"regexp":{"path":{"_name":"logs","boost":2,"value":"/var/.*"}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
@@ -0,0 +1,83 @@
+package esquerydsl
+
+import (
+	"fmt"
+	"time"
+)
+
+// RangeQuery is a typed value for Range QueryItems. Bounds keep their Go
+// types, so numeric bounds are emitted as JSON numbers, and nil bounds are
+// left out
+type RangeQuery struct {
+	Gt       interface{} `json:"gt,omitempty"`
+	Gte      interface{} `json:"gte,omitempty"`
+	Lt       interface{} `json:"lt,omitempty"`
+	Lte      interface{} `json:"lte,omitempty"`
+	Format   string      `json:"format,omitempty"`
+	TimeZone string      `json:"time_zone,omitempty"`
+	Boost    float64     `json:"boost,omitempty"`
+}
+
+// esDateLayout formats times as ES's strict_date_optional_time
+const esDateLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// TimeRange builds an inclusive Range QueryItem on field between from and
+// to, formatting both as strict_date_optional_time. A zero time leaves that
+// bound open
+func TimeRange(field string, from, to time.Time) QueryItem {
+	bounds := map[string]interface{}{
+		"format": "strict_date_optional_time",
+	}
+	if !from.IsZero() {
+		bounds["gte"] = from.Format(esDateLayout)
+	}
+	if !to.IsZero() {
+		bounds["lte"] = to.Format(esDateLayout)
+	}
+
+	return QueryItem{
+		Field: field,
+		Value: bounds,
+		Type:  Range,
+	}
+}
+
+// RecentWindow builds a Range QueryItem matching values of field within
+// the last dur, using ES date math (eg: 15 minutes is "now-15m") so that
+// the window is resolved by ES at query time. Date math has no unit below
+// milliseconds, so dur is rounded to the nearest one, and durations that
+// round to 0 or less are clamped to "now"
+func RecentWindow(field string, dur time.Duration) QueryItem {
+	gte := "now"
+	if dur = dur.Round(time.Millisecond); dur > 0 {
+		gte = "now-" + dateMathDuration(dur)
+	}
+
+	return QueryItem{
+		Field: field,
+		Value: map[string]interface{}{
+			"gte": gte,
+		},
+		Type: Range,
+	}
+}
+
+// dateMathDuration formats a positive, whole millisecond dur in the
+// largest date math unit that represents it exactly
+func dateMathDuration(dur time.Duration) string {
+	units := []struct {
+		size time.Duration
+		unit string
+	}{
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+	}
+	for _, unit := range units {
+		if dur%unit.size == 0 {
+			return fmt.Sprintf("%d%s", dur/unit.size, unit.unit)
+		}
+	}
+	return fmt.Sprintf("%dms", dur/time.Millisecond)
+}
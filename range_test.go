@@ -0,0 +1,79 @@
+package esquerydsl
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimeRange(t *testing.T) {
+	to := time.Date(2020, time.March, 1, 12, 30, 0, 0, time.UTC)
+	body, err := json.Marshal(QueryDoc{
+		Index:  "some_index",
+		Filter: []QueryItem{TimeRange("created_at", time.Time{}, to)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"filter":[{"range":{"created_at":{"format":"strict_date_optional_time","lte":"2020-03-01T12:30:00.000Z"}}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestRangeQueryOpenBound(t *testing.T) {
+	body, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Filter: []QueryItem{
+			{
+				Field: "price",
+				Value: RangeQuery{Gte: 100},
+				Type:  Range,
+			},
+			{
+				Field: "stock",
+				Value: RangeQuery{Gt: 0, Lt: 50.5},
+				Type:  Range,
+				Name:  "low_stock",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"filter":[{"range":{"price":{"gte":100}}},{"range":{"stock":{"_name":"low_stock","gt":0,"lt":50.5}}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
+
+func TestRecentWindow(t *testing.T) {
+	tests := []struct {
+		dur      time.Duration
+		expected string
+	}{
+		{15 * time.Minute, `{"range":{"@timestamp":{"gte":"now-15m"}}}`},
+		{2 * time.Hour, `{"range":{"@timestamp":{"gte":"now-2h"}}}`},
+		{90 * time.Minute, `{"range":{"@timestamp":{"gte":"now-90m"}}}`},
+		{30 * time.Second, `{"range":{"@timestamp":{"gte":"now-30s"}}}`},
+		{48 * time.Hour, `{"range":{"@timestamp":{"gte":"now-2d"}}}`},
+		{1500 * time.Millisecond, `{"range":{"@timestamp":{"gte":"now-1500ms"}}}`},
+		{1500 * time.Microsecond, `{"range":{"@timestamp":{"gte":"now-2ms"}}}`},
+		{1400 * time.Microsecond, `{"range":{"@timestamp":{"gte":"now-1ms"}}}`},
+		{400 * time.Microsecond, `{"range":{"@timestamp":{"gte":"now"}}}`},
+		{0, `{"range":{"@timestamp":{"gte":"now"}}}`},
+		{-15 * time.Minute, `{"range":{"@timestamp":{"gte":"now"}}}`},
+	}
+
+	for _, test := range tests {
+		body, err := json.Marshal(toLeafQuery(RecentWindow("@timestamp", test.dur)))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		if string(body) != test.expected {
+			t.Errorf("\nWant: %q\nHave: %q", test.expected, string(body))
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package esquerydsl
+
+import "encoding/json"
+
+type scrollDoc struct {
+	Scroll   string `json:"scroll,omitempty"`
+	ScrollID string `json:"scroll_id"`
+}
+
+// ScrollContinue builds the body of a _search/scroll request fetching the
+// next page of the scroll scrollID, keeping the scroll alive for keepAlive
+// (eg: "1m"). An empty keepAlive leaves the keep alive as is
+func ScrollContinue(scrollID, keepAlive string) ([]byte, error) {
+	return json.Marshal(scrollDoc{
+		Scroll:   keepAlive,
+		ScrollID: scrollID,
+	})
+}
@@ -0,0 +1,38 @@
+package esquerydsl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestScrollInitialBody(t *testing.T) {
+	query := QueryDoc{
+		Index: "some_index",
+		Size:  1000,
+		Filter: []QueryItem{
+			{Field: "status", Value: "published", Type: Term},
+		},
+		Scroll: "1m",
+	}
+
+	body, _ := json.Marshal(query)
+	expected := `{"query":{"bool":{"filter":[{"term":{"status":"published"}}]}},"size":1000}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+	if query.Scroll != "1m" {
+		t.Errorf("\nWant: %q\nHave: %q", "1m", query.Scroll)
+	}
+}
+
+func TestScrollContinue(t *testing.T) {
+	body, err := ScrollContinue("DXF1ZXJ5QW5kRmV0Y2gBAAAAAAAAAD4WYm9laVYtZndUQlNsdDcwakFMNjU1QQ==", "1m")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"scroll":"1m","scroll_id":"DXF1ZXJ5QW5kRmV0Y2gBAAAAAAAAAD4WYm9laVYtZndUQlNsdDcwakFMNjU1QQ=="}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+}
@@ -0,0 +1,96 @@
+package esquerydsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultSize is the number of hits ES returns when no size is sent
+const defaultSize = 10
+
+// MaxResultWindow mirrors the index.max_result_window ES setting, which
+// caps how deep from + size pagination can go. Update it if the indices
+// being queried use a non default value
+var MaxResultWindow = 10000
+
+// ValidationErr is returned by Validate and lists every problem
+// found in the QueryDoc
+type ValidationErr struct {
+	Problems []string
+}
+
+func (e *ValidationErr) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the QueryDoc for problems that ES would only
+// report at query time
+func (query QueryDoc) Validate() error {
+	var problems []string
+	size := query.Size
+	if size == 0 && !query.sizeSet {
+		size = defaultSize
+	}
+	if window := query.From + size; window > MaxResultWindow {
+		problems = append(problems, fmt.Sprintf(
+			"from + size (%d) exceeds the max result window of %d, use search_after or a point in time to page this deep",
+			window, MaxResultWindow,
+		))
+	}
+
+	for collapse := query.Collapse; collapse != nil && collapse.InnerHits != nil; collapse = collapse.InnerHits.Collapse {
+		if collapse.InnerHits.Name == "" {
+			problems = append(problems, fmt.Sprintf("inner_hits of the collapse on %q must have a name", collapse.Field))
+		}
+		if collapse.InnerHits.Size <= 0 {
+			problems = append(problems, fmt.Sprintf("inner_hits of the collapse on %q should set a size", collapse.Field))
+		}
+	}
+
+	if query.Collapse != nil && len(query.SearchAfter) > 0 {
+		if fields := sortFields(query); len(fields) != 1 || fields[0] != query.Collapse.Field {
+			problems = append(problems, fmt.Sprintf(
+				"search_after with a collapse on %q requires sorting on %q alone",
+				query.Collapse.Field, query.Collapse.Field,
+			))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationErr{Problems: problems}
+	}
+	return nil
+}
+
+// sortFields returns the fields the query sorts on, in order. SortBy
+// entries that are neither field names, {"field": "order"} maps nor
+// SortField clauses are left out
+func sortFields(query QueryDoc) []string {
+	var fields []string
+	if len(query.SortBy) == 0 {
+		for _, sort := range query.Sort {
+			for field := range sort {
+				fields = append(fields, field)
+			}
+		}
+		return fields
+	}
+
+	for _, sort := range query.SortBy {
+		switch sort := sort.(type) {
+		case string:
+			fields = append(fields, sort)
+		case SortField:
+			fields = append(fields, sort.Field)
+		case map[string]string:
+			for field := range sort {
+				fields = append(fields, field)
+			}
+		case map[string]interface{}:
+			for field := range sort {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
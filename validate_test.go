@@ -0,0 +1,117 @@
+package esquerydsl
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateDeepPagination(t *testing.T) {
+	err := QueryDoc{Index: "some_index", From: 9990, Size: 20}.Validate()
+
+	var validationErr *ValidationErr
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("\nUnexpected error: %v", err)
+	}
+
+	expected := "from + size (10010) exceeds the max result window of 10000, use search_after or a point in time to page this deep"
+	if validationErr.Error() != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, validationErr.Error())
+	}
+}
+
+func TestValidateDeepPaginationWindow(t *testing.T) {
+	defer func(window int) { MaxResultWindow = window }(MaxResultWindow)
+	MaxResultWindow = 50000
+
+	if err := (QueryDoc{Index: "some_index", From: 9990, Size: 20}).Validate(); err != nil {
+		t.Errorf("\nUnexpected error: %v", err)
+	}
+}
+
+func TestValidateDeepPaginationDefaultSize(t *testing.T) {
+	var validationErr *ValidationErr
+	if err := (QueryDoc{Index: "some_index", From: 9995}).Validate(); !errors.As(err, &validationErr) {
+		t.Errorf("\nUnexpected error: %v", err)
+	}
+
+	if err := (QueryDoc{Index: "some_index", From: 9995}.WithSize(0)).Validate(); err != nil {
+		t.Errorf("\nUnexpected error: %v", err)
+	}
+}
+
+func TestValidateScoringInFilter(t *testing.T) {
+	err := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			AllOfAnyOf(map[string][]interface{}{
+				"serviceName": {"a", "b"},
+				"logKind":     {"x", "y"},
+			}),
+		},
+		Filter: []QueryItem{
+			AnyPhrase("body", "quick brown fox", "lazy dog"),
+			{Field: "status", Value: "published", Type: Match},
+		},
+	}.Validate()
+
+	if err != nil {
+		t.Errorf("\nUnexpected error: %v", err)
+	}
+}
+
+func TestValidateCollapseInnerHits(t *testing.T) {
+	err := QueryDoc{
+		Index: "some_index",
+		Collapse: &Collapse{
+			Field: "thread_id",
+			InnerHits: &InnerHits{
+				Name: "by_author",
+				Size: 5,
+				Sort: []interface{}{map[string]string{"date": "desc"}},
+				Collapse: &Collapse{
+					Field:     "author",
+					InnerHits: &InnerHits{},
+				},
+			},
+		},
+	}.Validate()
+
+	var validationErr *ValidationErr
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("\nUnexpected error: %v", err)
+	}
+
+	expected := []string{
+		`inner_hits of the collapse on "author" must have a name`,
+		`inner_hits of the collapse on "author" should set a size`,
+	}
+	if !reflect.DeepEqual(validationErr.Problems, expected) {
+		t.Errorf("\nWant: %q\nHave: %q", expected, validationErr.Problems)
+	}
+}
+
+func TestValidateCollapseSearchAfter(t *testing.T) {
+	query := QueryDoc{
+		Index:       "some_index",
+		Collapse:    &Collapse{Field: "user"},
+		Sort:        []map[string]string{{"date": "desc"}},
+		SearchAfter: []interface{}{1463538857},
+	}
+
+	var validationErr *ValidationErr
+	if !errors.As(query.Validate(), &validationErr) {
+		t.Fatalf("\nExpected a ValidationErr")
+	}
+
+	expected := []string{`search_after with a collapse on "user" requires sorting on "user" alone`}
+	if !reflect.DeepEqual(validationErr.Problems, expected) {
+		t.Errorf("\nWant: %q\nHave: %q", expected, validationErr.Problems)
+	}
+
+	query.Sort = nil
+	query.SortBy = []interface{}{SortField{Field: "user", Order: "asc"}}
+	if err := query.Validate(); err != nil {
+		t.Errorf("\nUnexpected error: %v", err)
+	}
+}
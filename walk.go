@@ -0,0 +1,395 @@
+package esquerydsl
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ClauseCount returns the total number of leaf clauses in the QueryDoc,
+// including the ones within nested wraps, nested, has_child, has_parent and
+// function_score queries as well as filter and filters aggregations. It is
+// handy for guarding against ES's max_clause_count
+func (query QueryDoc) ClauseCount() int {
+	return countClauses(allItems(query))
+}
+
+// Walk calls fn for every QueryItem in the QueryDoc, depth first, including
+// compound queries and the items they wrap. The queries of filter and filters
+// aggregations are walked after the query clauses. Returning an error from fn
+// aborts the walk and that error is returned
+func (query QueryDoc) Walk(fn func(QueryItem) error) error {
+	return walkItems(allItems(query), fn)
+}
+
+func walkItems(items []QueryItem, fn func(QueryItem) error) error {
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			return err
+		}
+		if children, ok := childItems(item); ok {
+			if err := walkItems(children, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Transform returns a copy of the QueryDoc with fn applied to every leaf
+// QueryItem, rebuilding any compound queries along the way, the queries of
+// filter and filters aggregations included. It is handy for enforcing rules
+// such as field allow lists on queries from untrusted callers
+func (query QueryDoc) Transform(fn func(QueryItem) QueryItem) QueryDoc {
+	transformClause := func(clause childClause) []QueryItem {
+		return transformItems(clause.items, fn)
+	}
+	query.And, query.Not, query.Or, query.Filter = mapClauses("", query.And, query.Not, query.Or, query.Filter, transformClause)
+	query.Aggs = mapAggs(query.Aggs, transformClause)
+	return query
+}
+
+func transformItems(items []QueryItem, fn func(QueryItem) QueryItem) []QueryItem {
+	if items == nil {
+		return nil
+	}
+
+	transformed := make([]QueryItem, 0, len(items))
+	for _, item := range items {
+		transformed = append(transformed, transformItem(item, fn))
+	}
+	return transformed
+}
+
+func transformItem(item QueryItem, fn func(QueryItem) QueryItem) QueryItem {
+	transformed, ok := mapChildren(item, func(clause childClause) []QueryItem {
+		return transformItems(clause.items, fn)
+	})
+	if ok {
+		return transformed
+	}
+	return fn(item)
+}
+
+func countClauses(items []QueryItem) int {
+	count := 0
+	for _, item := range items {
+		if children, ok := childItems(item); ok {
+			count += countClauses(children)
+		} else {
+			count++
+		}
+	}
+	return count
+}
+
+// allItems returns every clause of the query, in must, must_not,
+// should and filter order, followed by the queries of its aggregations
+func allItems(query QueryDoc) []QueryItem {
+	items := clauseItems(query)
+	mapAggs(query.Aggs, func(clause childClause) []QueryItem {
+		items = append(items, clause.items...)
+		return clause.items
+	})
+	return items
+}
+
+// clauseItems returns every clause of the query, in must, must_not,
+// should and filter order
+func clauseItems(q query) []QueryItem {
+	items := make([]QueryItem, 0)
+	items = append(items, q.andList()...)
+	items = append(items, q.notList()...)
+	items = append(items, q.orList()...)
+	items = append(items, q.filterList()...)
+	return items
+}
+
+// childItems returns the items wrapped by item, if item is a compound
+// query, and reports whether it was one
+func childItems(item QueryItem) ([]QueryItem, bool) {
+	children := make([]QueryItem, 0)
+	_, ok := mapChildren(item, func(clause childClause) []QueryItem {
+		children = append(children, clause.items...)
+		return clause.items
+	})
+	if !ok {
+		return nil, false
+	}
+	return children, true
+}
+
+// childClause is a list of items wrapped by a compound query. Path locates
+// it within the compound query, eg: "bool.filter" or "has_child.query",
+// single is set when it holds a lone query rather than a list, and filter
+// when its items are in filter context
+type childClause struct {
+	path   string
+	single bool
+	filter bool
+	items  []QueryItem
+}
+
+// mapChildren returns a copy of item with each of the clauses it wraps
+// replaced by what fn returns for it, in must, must_not, should and filter
+// order, and reports whether item is a compound query. This is the one place
+// that knows how compound queries wrap their items
+func mapChildren(item QueryItem, fn func(childClause) []QueryItem) (QueryItem, bool) {
+	switch value := item.Value.(type) {
+	case QueryDoc:
+		if item.Type == Nested {
+			value.And, value.Not, value.Or, value.Filter = mapClauses("bool", value.And, value.Not, value.Or, value.Filter, fn)
+			item.Value = value
+			return item, true
+		}
+	case NestedQueryItem:
+		if item.Type == NestedQuery {
+			value.And, value.Not, value.Or, value.Filter = mapClauses("nested", value.And, value.Not, value.Or, value.Filter, fn)
+			item.Value = value
+			return item, true
+		}
+	case HasChildQueryItem:
+		if item.Type == HasChild {
+			value.Query = mapQuery("has_child.query", value.Query, fn)
+			item.Value = value
+			return item, true
+		}
+	case HasParentQueryItem:
+		if item.Type == HasParent {
+			value.Query = mapQuery("has_parent.query", value.Query, fn)
+			item.Value = value
+			return item, true
+		}
+	case FunctionScoreQueryItem:
+		if item.Type == FunctionScore && (value.Query.Value != nil || hasFunctionFilters(value.Functions)) {
+			if value.Query.Value != nil {
+				value.Query = mapQuery("function_score.query", value.Query, fn)
+			}
+			value.Functions = mapFunctionFilters(value.Functions, fn)
+			item.Value = value
+			return item, true
+		}
+	}
+	return item, false
+}
+
+func hasFunctionFilters(functions []ScoreFunction) bool {
+	for _, function := range functions {
+		if function.Filter != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// mapFunctionFilters returns a copy of functions with fn applied to
+// their filters, which are in filter context
+func mapFunctionFilters(functions []ScoreFunction, fn func(childClause) []QueryItem) []ScoreFunction {
+	if functions == nil {
+		return nil
+	}
+
+	mapped := make([]ScoreFunction, 0, len(functions))
+	for i, function := range functions {
+		if function.Filter != nil {
+			filter := fn(childClause{
+				path:   fmt.Sprintf("function_score.functions[%d].filter", i),
+				single: true,
+				filter: true,
+				items:  []QueryItem{*function.Filter},
+			})[0]
+			function.Filter = &filter
+		}
+		mapped = append(mapped, function)
+	}
+	return mapped
+}
+
+// mapAggs returns a copy of aggs with fn applied to the query of each
+// filter aggregation and to the queries of each filters aggregation, sub
+// aggregations included. Aggregations are mapped in name order
+func mapAggs(aggs map[string]Agg, fn func(childClause) []QueryItem) map[string]Agg {
+	if aggs == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(aggs))
+	for name := range aggs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mapped := make(map[string]Agg, len(aggs))
+	for _, name := range names {
+		agg := aggs[name]
+		path := "aggs." + name + "." + agg.Type
+		switch body := agg.Body.(type) {
+		case leafQuery:
+			if agg.Type == "filter" {
+				agg.Body = toLeafQuery(mapQuery(path, body.queryItem(), fn))
+			}
+		case map[string]interface{}:
+			if filters, ok := body["filters"].(map[string]leafQuery); ok && agg.Type == "filters" {
+				agg.Body = mapFiltersBody(path, body, filters, fn)
+			}
+		}
+		agg.Aggs = mapAggs(agg.Aggs, fn)
+		mapped[name] = agg
+	}
+	return mapped
+}
+
+// mapFiltersBody returns a copy of the body of a filters aggregation with fn
+// applied to each of its named queries, in name order
+func mapFiltersBody(path string, body map[string]interface{}, filters map[string]leafQuery, fn func(childClause) []QueryItem) map[string]interface{} {
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mappedFilters := make(map[string]leafQuery, len(filters))
+	for _, name := range names {
+		mappedFilters[name] = toLeafQuery(mapQuery(path+"."+name, filters[name].queryItem(), fn))
+	}
+
+	mapped := make(map[string]interface{}, len(body))
+	for key, value := range body {
+		mapped[key] = value
+	}
+	mapped["filters"] = mappedFilters
+	return mapped
+}
+
+// mapClauses applies fn to the four clause lists of a bool query, whose
+// paths are prefixed by prefix (if non empty)
+func mapClauses(prefix string, and, not, or, filter []QueryItem, fn func(childClause) []QueryItem) ([]QueryItem, []QueryItem, []QueryItem, []QueryItem) {
+	path := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+
+	and = fn(childClause{path: path("must"), items: and})
+	not = fn(childClause{path: path("must_not"), items: not})
+	or = fn(childClause{path: path("should"), items: or})
+	filter = fn(childClause{path: path("filter"), filter: true, items: filter})
+	return and, not, or, filter
+}
+
+func mapQuery(path string, query QueryItem, fn func(childClause) []QueryItem) QueryItem {
+	return fn(childClause{path: path, single: true, items: []QueryItem{query}})[0]
+}
+
+// visit calls fn for every QueryItem in the QueryDoc, depth first, along
+// with its path (eg: "filter[0].bool.should[1]") and whether it ends up in
+// filter context, either directly or within compound queries
+func (query QueryDoc) visit(fn func(item QueryItem, path string, inFilter bool)) {
+	mapClauses("", query.And, query.Not, query.Or, query.Filter, func(clause childClause) []QueryItem {
+		visitClause(clause, "", false, fn)
+		return clause.items
+	})
+}
+
+func visitClause(clause childClause, parent string, inFilter bool, fn func(QueryItem, string, bool)) {
+	path := clause.path
+	if parent != "" {
+		path = parent + "." + path
+	}
+	inFilter = inFilter || clause.filter
+
+	for i, item := range clause.items {
+		itemPath := path
+		if !clause.single {
+			itemPath = fmt.Sprintf("%s[%d]", path, i)
+		}
+
+		fn(item, itemPath, inFilter)
+		mapChildren(item, func(child childClause) []QueryItem {
+			visitClause(child, itemPath, inFilter, fn)
+			return child.items
+		})
+	}
+}
+
+// nameInnerHits returns a copy of the query where nested queries sharing a
+// path get distinct inner_hits names, as ES rejects duplicates. Unnamed
+// inner_hits on a shared path are named path_index, index counting up from 0
+// in clause order and skipping names the caller already used
+func nameInnerHits(query QueryDoc) QueryDoc {
+	counts := make(map[string]int)
+	taken := make(map[string]bool)
+	shared := false
+	_ = walkItems(clauseItems(query), func(item QueryItem) error {
+		if nested, ok := item.Value.(NestedQueryItem); ok && item.Type == NestedQuery && nested.InnerHits != nil {
+			counts[item.Field]++
+			shared = shared || counts[item.Field] > 1
+			if nested.InnerHits.Name != "" {
+				taken[nested.InnerHits.Name] = true
+			}
+		}
+		return nil
+	})
+	if !shared {
+		return query
+	}
+
+	namer := &innerHitsNamer{counts: counts, taken: taken, next: make(map[string]int)}
+	query.And, query.Not, query.Or, query.Filter = mapClauses("", query.And, query.Not, query.Or, query.Filter,
+		func(clause childClause) []QueryItem {
+			return namer.nameItems(clause.items)
+		},
+	)
+	return query
+}
+
+// innerHitsNamer hands out the inner_hits names of nameInnerHits. Counts
+// holds the number of inner_hits per path, taken the names in use and next
+// the index to try next per path
+type innerHitsNamer struct {
+	counts map[string]int
+	taken  map[string]bool
+	next   map[string]int
+}
+
+func (n *innerHitsNamer) nameItems(items []QueryItem) []QueryItem {
+	if items == nil {
+		return nil
+	}
+
+	named := make([]QueryItem, 0, len(items))
+	for _, item := range items {
+		named = append(named, n.nameItem(item))
+	}
+	return named
+}
+
+func (n *innerHitsNamer) nameItem(item QueryItem) QueryItem {
+	if nested, ok := item.Value.(NestedQueryItem); ok && item.Type == NestedQuery && nested.InnerHits != nil {
+		if nested.InnerHits.Name == "" && n.counts[item.Field] > 1 {
+			innerHits := *nested.InnerHits
+			innerHits.Name = n.name(item.Field)
+			nested.InnerHits = &innerHits
+			item.Value = nested
+		}
+	}
+
+	named, _ := mapChildren(item, n.nameClause)
+	return named
+}
+
+func (n *innerHitsNamer) nameClause(clause childClause) []QueryItem {
+	return n.nameItems(clause.items)
+}
+
+// name returns the first path_index name that isn't taken yet, and takes it
+func (n *innerHitsNamer) name(path string) string {
+	for {
+		name := fmt.Sprintf("%s_%d", path, n.next[path])
+		n.next[path]++
+		if !n.taken[name] {
+			n.taken[name] = true
+			return name
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package esquerydsl
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestClauseCount(t *testing.T) {
+	query := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{Field: "title", Value: "Search", Type: Match},
+			WrapQueryItems("or",
+				QueryItem{Field: "status", Value: "draft", Type: Term},
+				QueryItem{Field: "status", Value: "published", Type: Term},
+			),
+		},
+		Filter: []QueryItem{
+			{
+				Field: "comments",
+				Value: NestedQueryItem{
+					And: []QueryItem{
+						{Field: "comments.author", Value: "kimchy", Type: Match},
+					},
+					Not: []QueryItem{
+						Not(QueryItem{Field: "comments.hidden", Value: true, Type: Term}),
+					},
+				},
+				Type: NestedQuery,
+			},
+			{
+				Value: FunctionScoreQueryItem{
+					Query: AllOf(
+						QueryItem{Field: "tags", Value: "featured", Type: Term},
+						QueryItem{Field: "lang", Value: "en", Type: Term},
+					),
+				},
+				Type: FunctionScore,
+			},
+		},
+	}
+
+	if count := query.ClauseCount(); count != 7 {
+		t.Errorf("\nWant: %d\nHave: %d", 7, count)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	errWildcard := errors.New("wildcard queries are not allowed")
+	query := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{Field: "title", Value: "Search", Type: Match},
+			WrapQueryItems("or",
+				QueryItem{Field: "status", Value: "draft", Type: Term},
+				AllOf(QueryItem{Field: "user", Value: "ki*y", Type: Wildcard}),
+			),
+		},
+	}
+
+	visited := 0
+	err := query.Walk(func(item QueryItem) error {
+		visited++
+		if item.Type == Wildcard {
+			return errWildcard
+		}
+		return nil
+	})
+
+	if !errors.Is(err, errWildcard) {
+		t.Errorf("\nUnexpected error: %v", err)
+	}
+	if visited != 5 {
+		t.Errorf("\nWant: %d\nHave: %d", 5, visited)
+	}
+}
+
+func TestWalkAggsAndFunctionFilters(t *testing.T) {
+	query := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Value: FunctionScoreQueryItem{
+					Functions: []ScoreFunction{
+						FieldValueFactor("likes", 1.2, "sqrt", 1),
+						{Filter: &QueryItem{Field: "tags", Value: "featured", Type: Term}, Weight: 2},
+					},
+				},
+				Type: FunctionScore,
+			},
+		},
+		Aggs: map[string]Agg{
+			"published": FilterAgg(QueryItem{Field: "status", Value: "published", Type: Term}).
+				SubAgg("langs", FiltersAgg(map[string]QueryItem{
+					"en": {Field: "lang", Value: "en", Type: Term},
+					"fr": AllOf(QueryItem{Field: "lang", Value: "fr", Type: Term}),
+				})),
+			"tags": TermsAgg("tags", 10),
+		},
+	}
+
+	var fields []string
+	_ = query.Walk(func(item QueryItem) error {
+		fields = append(fields, item.Field)
+		return nil
+	})
+
+	expected := []string{"", "tags", "status", "lang", "", "lang"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("\nWant: %q\nHave: %q", expected, fields)
+	}
+
+	if count := query.ClauseCount(); count != 4 {
+		t.Errorf("\nWant: %d\nHave: %d", 4, count)
+	}
+}
+
+func TestWalkNoError(t *testing.T) {
+	query := QueryDoc{
+		Index: "some_index",
+		And:   []QueryItem{{Field: "title", Value: "Search", Type: Match}},
+	}
+
+	if err := query.Walk(func(QueryItem) error { return nil }); err != nil {
+		t.Errorf("\nUnexpected error: %v", err)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	query := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{Field: "title", Value: "Search", Type: Match},
+			WrapQueryItems("or",
+				QueryItem{Field: "secret", Value: "hunter2", Type: Match},
+				QueryItem{Field: "status", Value: "published", Type: Term},
+			),
+		},
+		Filter: []QueryItem{
+			{Field: "secret", Value: "hunter2", Type: Match},
+		},
+	}
+
+	transformed := query.Transform(func(item QueryItem) QueryItem {
+		if item.Type == Match && item.Field == "secret" {
+			return QueryItem{Type: MatchNone}
+		}
+		return item
+	})
+
+	body, _ := json.Marshal(transformed)
+	expected := `{"query":{"bool":{"must":[{"match":{"title":"Search"}},{"bool":{"should":[{"match_none":{}},{"term":{"status":"published"}}]}}],"filter":[{"match_none":{}}]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	// the original query must be left untouched
+	if query.Filter[0].Type != Match {
+		t.Errorf("\nUnexpected change to the original query: %v", query.Filter[0])
+	}
+}
+
+func TestTransformAggsAndFunctionFilters(t *testing.T) {
+	secret := QueryItem{Field: "secret", Value: "hunter2", Type: Term}
+	query := QueryDoc{
+		Index: "some_index",
+		And: []QueryItem{
+			{
+				Value: FunctionScoreQueryItem{
+					Functions: []ScoreFunction{{Filter: &secret, Weight: 2}},
+				},
+				Type: FunctionScore,
+			},
+		},
+		Aggs: map[string]Agg{
+			"published": FilterAgg(QueryItem{Field: "status", Value: "published", Type: Term}).
+				SubAgg("leaks", FiltersAgg(map[string]QueryItem{
+					"secret": AllOf(secret),
+				})),
+		},
+	}
+
+	transformed := query.Transform(func(item QueryItem) QueryItem {
+		if item.Field == "secret" {
+			return QueryItem{Type: MatchNone}
+		}
+		return item
+	})
+
+	body, _ := json.Marshal(transformed)
+	expected := `{"query":{"bool":{"must":[{"function_score":{"functions":[{"filter":{"match_none":{}},"weight":2}]}}]}},"aggs":{"published":{"filter":{"term":{"status":"published"}},"aggs":{"leaks":{"filters":{"filters":{"secret":{"bool":{"must":[{"match_none":{}}]}}}}}}}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	// the original query must be left untouched
+	original, _ := json.Marshal(query)
+	if EqualJSON(original, body) {
+		t.Errorf("\nUnexpected change to the original query: %s", original)
+	}
+}
+
+func TestNestedInnerHitsNames(t *testing.T) {
+	nested := func(author string, innerHits *InnerHits) QueryItem {
+		return QueryItem{
+			Field: "comments",
+			Value: NestedQueryItem{
+				And: []QueryItem{
+					{Field: "comments.author", Value: author, Type: Term},
+				},
+				InnerHits: innerHits,
+			},
+			Type: NestedQuery,
+		}
+	}
+	query := QueryDoc{
+		Index: "some_index",
+		Or: []QueryItem{
+			nested("kimchy", &InnerHits{}),
+			nested("shay", &InnerHits{Size: 2}),
+			nested("clint", &InnerHits{Name: "by_clint"}),
+		},
+	}
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := `{"query":{"bool":{"should":[` +
+		`{"nested":{"inner_hits":{"name":"comments_0"},"path":["comments"],"query":{"bool":{"must":[{"term":{"comments.author":"kimchy"}}]}}}},` +
+		`{"nested":{"inner_hits":{"name":"comments_1","size":2},"path":["comments"],"query":{"bool":{"must":[{"term":{"comments.author":"shay"}}]}}}},` +
+		`{"nested":{"inner_hits":{"name":"by_clint"},"path":["comments"],"query":{"bool":{"must":[{"term":{"comments.author":"clint"}}]}}}}` +
+		`]}}}`
+	if string(body) != expected {
+		t.Errorf("\nWant: %q\nHave: %q", expected, string(body))
+	}
+
+	// the caller's inner_hits are left untouched
+	if name := query.Or[0].Value.(NestedQueryItem).InnerHits.Name; name != "" {
+		t.Errorf("\nWant: %q\nHave: %q", "", name)
+	}
+}
+
+func TestNestedInnerHitsNamesTaken(t *testing.T) {
+	nested := func(innerHits *InnerHits) QueryItem {
+		return QueryItem{
+			Field: "c",
+			Value: NestedQueryItem{
+				And: []QueryItem{
+					{Field: "c.author", Value: "kimchy", Type: Term},
+				},
+				InnerHits: innerHits,
+			},
+			Type: NestedQuery,
+		}
+	}
+
+	body, err := json.Marshal(QueryDoc{
+		Index: "some_index",
+		Or: []QueryItem{
+			nested(&InnerHits{}),
+			nested(&InnerHits{}),
+			nested(&InnerHits{Name: "c_1"}),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var doc struct {
+		Query struct {
+			Bool struct {
+				Should []struct {
+					Nested struct {
+						InnerHits InnerHits `json:"inner_hits"`
+					} `json:"nested"`
+				} `json:"should"`
+			} `json:"bool"`
+		} `json:"query"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var names []string
+	for _, should := range doc.Query.Bool.Should {
+		names = append(names, should.Nested.InnerHits.Name)
+	}
+	expected := []string{"c_0", "c_2", "c_1"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("\nWant: %q\nHave: %q", expected, names)
+	}
+}